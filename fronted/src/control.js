@@ -0,0 +1,114 @@
+// control.js
+// 二进制控制协议（版本 2），与桌面端 windows-client/control.go 一致。
+// 消息头依次为版本（uint8）、动作（uint8）、序号（uint32）和发送时间（uint64，Unix 毫秒），
+// 其后为动作对应的消息体，所有整数均为大端序。
+
+const CONTROL_PROTOCOL_VERSION = 2;
+const HEADER_SIZE = 14;
+
+// 动作编号
+const actions = {
+  mouse_move: 1,
+  mouse_down: 2,
+  mouse_up: 3,
+  mouse_click: 4,
+  double_click: 5,
+  mouse_wheel: 6,
+  key_down: 7,
+  key_up: 8,
+  key_press: 9,
+  select_display: 10,
+  capture_region: 11,
+  capture_window: 12,
+  type_text: 13,
+  request_control: 14,
+  clipboard: 15,
+  clipboard_sync: 16
+};
+
+// 鼠标按键编号，与 MouseEvent.button 一致
+const mouseButtons = { left: 0, middle: 1, right: 2 };
+
+// 剪贴板格式编号
+const clipboardFormats = { text: 0, 'image/png': 1 };
+
+// 修饰键位
+const modifierBits = { ctrl: 1, shift: 2, alt: 4, cmd: 8 };
+
+// 各字段类型的长度，字符串为长度前缀加 UTF-8 内容
+const fieldSizes = { u8: 1, u16: 2, i16: 2, i32: 4 };
+
+const textEncoder = new TextEncoder();
+
+// 各动作的消息体字段
+const bodies = {
+  mouse_move: ({ x, y, width, height }) => [['u16', x], ['u16', y], ['u16', width], ['u16', height]],
+  mouse_down: ({ button }) => [['u8', mouseButtons[button]]],
+  mouse_up: ({ button }) => [['u8', mouseButtons[button]]],
+  mouse_click: ({ button }) => [['u8', mouseButtons[button]]],
+  double_click: ({ button }) => [['u8', mouseButtons[button]]],
+  mouse_wheel: ({ deltaX, deltaY }) => [['i16', deltaX], ['i16', deltaY]],
+  key_down: keyBody,
+  key_up: keyBody,
+  key_press: keyBody,
+  select_display: ({ index }) => [['i16', index]],
+  capture_region: ({ x, y, width, height }) => [['i32', x], ['i32', y], ['i32', width], ['i32', height]],
+  capture_window: ({ title }) => [['str16', textEncoder.encode(title)]],
+  type_text: ({ text }) => [['str16', textEncoder.encode(text)]],
+  request_control: () => [],
+  // 剪贴板内容分块发送，data 为该块的字节（Uint8Array），final 表示最后一块
+  clipboard: ({ format, data, final }) => [['u8', clipboardFormats[format]], ['u8', final ? 1 : 0], ['str16', data]],
+  clipboard_sync: ({ enabled }) => [['u8', enabled ? 1 : 0]]
+};
+
+// 按键以 KeyboardEvent.code 表示物理按键；修饰键通常作为普通按键单独发送，
+// modifiers 只用于 key_press 一次发送组合键
+function keyBody({ code, modifiers = [] }) {
+  const flags = modifiers.reduce((bits, modifier) => bits | modifierBits[modifier], 0);
+  return [['u8', flags], ['str8', textEncoder.encode(code)]];
+}
+
+// encodeControl 将控制动作编码为二进制消息
+export function encodeControl(action, sequence, event) {
+  const fields = bodies[action](event);
+  let size = HEADER_SIZE;
+  for (const [type, value] of fields) {
+    size += type === 'str8' ? 1 + value.length : type === 'str16' ? 2 + value.length : fieldSizes[type];
+  }
+
+  const buffer = new ArrayBuffer(size);
+  const view = new DataView(buffer);
+  view.setUint8(0, CONTROL_PROTOCOL_VERSION);
+  view.setUint8(1, actions[action]);
+  view.setUint32(2, sequence);
+  view.setBigUint64(6, BigInt(Date.now()));
+  let offset = HEADER_SIZE;
+  for (const [type, value] of fields) {
+    switch (type) {
+      case 'u8':
+        view.setUint8(offset, value);
+        break;
+      case 'u16':
+        view.setUint16(offset, value);
+        break;
+      case 'i16':
+        view.setInt16(offset, value);
+        break;
+      case 'i32':
+        view.setInt32(offset, value);
+        break;
+      case 'str8':
+        view.setUint8(offset, value.length);
+        new Uint8Array(buffer, offset + 1).set(value);
+        offset += 1 + value.length;
+        continue;
+      case 'str16':
+        view.setUint16(offset, value.length);
+        new Uint8Array(buffer, offset + 2).set(value);
+        offset += 2 + value.length;
+        continue;
+    }
+    offset += fieldSizes[type];
+  }
+  return buffer;
+}
@@ -0,0 +1,27 @@
+// control.test.js
+// 以桌面端 windows-client/testdata/control_v2.json 中的固定字节校验 encodeControl，
+// 桌面端的 TestDecodeBinaryControlFixtures 解码同一文件，两端的协议实现不会各自偏离。
+import { test } from 'node:test';
+import assert from 'node:assert/strict';
+import { readFileSync } from 'node:fs';
+import { encodeControl } from './control.js';
+
+const fixtures = JSON.parse(readFileSync(new URL('../../windows-client/testdata/control_v2.json', import.meta.url)));
+
+// 将十六进制字符串转换为字节
+function fromHex(hex) {
+  return Uint8Array.from(hex.match(/../g) ?? [], byte => parseInt(byte, 16));
+}
+
+for (const fixture of fixtures) {
+  test(fixture.name, (t) => {
+    t.mock.method(Date, 'now', () => fixture.time);
+    const event = { ...fixture.event };
+    // 剪贴板数据在样例中为字节数组，发送时为 Uint8Array
+    if (Array.isArray(event.data)) {
+      event.data = Uint8Array.from(event.data);
+    }
+    const encoded = new Uint8Array(encodeControl(fixture.action, fixture.sequence, event));
+    assert.deepEqual(encoded, fromHex(fixture.hex));
+  });
+}
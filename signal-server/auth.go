@@ -0,0 +1,151 @@
+// auth.go
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// 令牌校验失败的原因，直接作为 register_failed 的 reason 返回给客户端
+var (
+	errMissingToken    = errors.New("missing_token")
+	errInvalidToken    = errors.New("invalid_token")
+	errTokenExpired    = errors.New("token_expired")
+	errRoleNotGranted  = errors.New("role_not_granted")
+	errSessionMismatch = errors.New("session_not_granted")
+)
+
+// TokenClaims 定义注册令牌中携带的授权信息
+type TokenClaims struct {
+	Role      string `json:"role"`                // 授予的角色："viewer" 或 "desktop"
+	SessionID string `json:"session_id"`          // 授予访问的会话（即哪台桌面）
+	Subject   string `json:"sub,omitempty"`       // 令牌持有者，例如用户名，Desktop 据此显示和免确认放行 Viewer
+	ViewOnly  bool   `json:"view_only,omitempty"` // Viewer 只能观看，Desktop 拒绝其控制指令和控制请求
+	ExpiresAt int64  `json:"exp"`                 // 过期时间，Unix 秒，必须指定
+}
+
+// authSecret 用于签发和校验令牌的 HMAC 密钥，为 nil 时不校验令牌
+var authSecret []byte
+
+// issueToken 使用 HMAC-SHA256 签发令牌，格式为 base64url(claims).base64url(signature)
+func issueToken(secret []byte, claims TokenClaims) (string, error) {
+	body, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(body)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signToken(secret, encoded)), nil
+}
+
+// verifyToken 校验令牌签名和有效期，返回其中的授权信息；不带过期时间的令牌视为无效，
+// 泄露的令牌最多只能使用到过期
+func verifyToken(secret []byte, token string, now time.Time) (*TokenClaims, error) {
+	if token == "" {
+		return nil, errMissingToken
+	}
+	encoded, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, errInvalidToken
+	}
+	gotSig, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(gotSig, signToken(secret, encoded)) {
+		return nil, errInvalidToken
+	}
+	body, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	var claims TokenClaims
+	if err := json.Unmarshal(body, &claims); err != nil {
+		return nil, errInvalidToken
+	}
+	if claims.ExpiresAt == 0 {
+		return nil, errInvalidToken
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return nil, errTokenExpired
+	}
+	return &claims, nil
+}
+
+// authorizeRegister 校验注册请求的令牌，返回其授权信息，SessionID 为最终加入的会话。
+// 请求未指定会话时使用令牌授予的会话；未配置密钥时跳过校验，只填写请求的角色和会话。
+func authorizeRegister(token, role, sessionID string) (*TokenClaims, error) {
+	if authSecret == nil {
+		return &TokenClaims{Role: role, SessionID: sessionID}, nil
+	}
+	claims, err := verifyToken(authSecret, token, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if claims.Role != role {
+		return nil, errRoleNotGranted
+	}
+	if sessionID != "" && claims.SessionID != sessionID {
+		return nil, errSessionMismatch
+	}
+	return claims, nil
+}
+
+// signToken 计算令牌主体的 HMAC-SHA256 签名
+func signToken(secret []byte, encoded string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
+
+// runTokenCommand 实现 "token" 子命令：签发令牌并输出到标准输出
+func runTokenCommand(args []string) {
+	fs := flag.NewFlagSet("token", flag.ExitOnError)
+	secret := fs.String("auth-secret", os.Getenv("SIGNAL_AUTH_SECRET"), "签发令牌使用的 HMAC 密钥（默认读取 SIGNAL_AUTH_SECRET）")
+	role := fs.String("role", "viewer", "授予的角色：viewer 或 desktop")
+	sessionID := fs.String("session", "", "授予访问的会话 ID")
+	ttl := fs.Duration("ttl", 24*time.Hour, "令牌有效期，必须大于 0")
+	viewOnly := fs.Bool("view-only", false, "Viewer 只能观看，不能控制桌面")
+	subject := fs.String("subject", "", "令牌持有者，例如用户名，Desktop 可据此免确认放行")
+	fs.Parse(args)
+
+	if *secret == "" {
+		fmt.Fprintln(os.Stderr, "token: -auth-secret is required")
+		os.Exit(2)
+	}
+	if *role != "viewer" && *role != "desktop" {
+		fmt.Fprintln(os.Stderr, "token: -role must be viewer or desktop")
+		os.Exit(2)
+	}
+	if *ttl <= 0 {
+		fmt.Fprintln(os.Stderr, "token: -ttl must be greater than 0")
+		os.Exit(2)
+	}
+	if !validSessionID(*sessionID) {
+		fmt.Fprintln(os.Stderr, "token: -session is missing or invalid")
+		os.Exit(2)
+	}
+
+	if *viewOnly && *role != "viewer" {
+		fmt.Fprintln(os.Stderr, "token: -view-only only applies to viewer tokens")
+		os.Exit(2)
+	}
+
+	claims := TokenClaims{
+		Role:      *role,
+		SessionID: *sessionID,
+		Subject:   *subject,
+		ViewOnly:  *viewOnly,
+		ExpiresAt: time.Now().Add(*ttl).Unix(),
+	}
+	token, err := issueToken([]byte(*secret), claims)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "token:", err)
+		os.Exit(1)
+	}
+	fmt.Println(token)
+}
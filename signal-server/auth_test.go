@@ -0,0 +1,104 @@
+// auth_test.go
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyToken(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1700000000, 0)
+	valid := TokenClaims{Role: "viewer", SessionID: "office", Subject: "alice", ExpiresAt: now.Unix() + 60}
+	token := mustIssueToken(t, secret, valid)
+	body, sig, _ := strings.Cut(token, ".")
+	other := mustIssueToken(t, secret, TokenClaims{Role: "desktop", SessionID: "office"})
+	otherBody, _, _ := strings.Cut(other, ".")
+
+	tests := []struct {
+		name    string
+		secret  []byte
+		token   string
+		now     time.Time
+		want    *TokenClaims
+		wantErr error
+	}{
+		{"valid", secret, token, now, &valid, nil},
+		{"no expiry", secret, other, now, nil, errInvalidToken},
+		{"missing", secret, "", now, nil, errMissingToken},
+		{"no separator", secret, body, now, nil, errInvalidToken},
+		{"wrong secret", []byte("other"), token, now, nil, errInvalidToken},
+		{"tampered claims", secret, otherBody + "." + sig, now, nil, errInvalidToken},
+		{"bad signature encoding", secret, body + ".!!", now, nil, errInvalidToken},
+		{"one second before expiry", secret, token, now.Add(59 * time.Second), &valid, nil},
+		{"at expiry", secret, token, now.Add(60 * time.Second), nil, errTokenExpired},
+		{"after expiry", secret, token, now.Add(time.Hour), nil, errTokenExpired},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := verifyToken(tt.secret, tt.token, tt.now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verifyToken() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.want != nil && *got != *tt.want {
+				t.Errorf("verifyToken() = %+v, want %+v", *got, *tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizeRegister(t *testing.T) {
+	defer func(secret []byte) { authSecret = secret }(authSecret)
+
+	authSecret = nil
+	claims, err := authorizeRegister("", "viewer", "office")
+	if err != nil || claims.Role != "viewer" || claims.SessionID != "office" {
+		t.Fatalf("authorizeRegister() without secret = %+v, %v", claims, err)
+	}
+
+	authSecret = []byte("secret")
+	viewer := mustIssueToken(t, authSecret, TokenClaims{Role: "viewer", SessionID: "office", ViewOnly: true, ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	expired := mustIssueToken(t, authSecret, TokenClaims{Role: "viewer", SessionID: "office", ExpiresAt: time.Now().Add(-time.Minute).Unix()})
+	forged := mustIssueToken(t, []byte("other"), TokenClaims{Role: "viewer", SessionID: "office", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	permanent := mustIssueToken(t, authSecret, TokenClaims{Role: "viewer", SessionID: "office", ViewOnly: true})
+
+	tests := []struct {
+		name        string
+		token       string
+		role        string
+		sessionID   string
+		wantSession string
+		wantErr     error
+	}{
+		{"granted", viewer, "viewer", "office", "office", nil},
+		{"session from token", viewer, "viewer", "", "office", nil},
+		{"missing token", "", "viewer", "office", "", errMissingToken},
+		{"expired", expired, "viewer", "office", "", errTokenExpired},
+		{"no expiry", permanent, "viewer", "office", "", errInvalidToken},
+		{"bad signature", forged, "viewer", "office", "", errInvalidToken},
+		{"wrong role", viewer, "desktop", "office", "", errRoleNotGranted},
+		{"wrong session", viewer, "viewer", "lab", "", errSessionMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := authorizeRegister(tt.token, tt.role, tt.sessionID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("authorizeRegister() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (claims.SessionID != tt.wantSession || !claims.ViewOnly) {
+				t.Errorf("authorizeRegister() = %+v, want session %q and view only", *claims, tt.wantSession)
+			}
+		})
+	}
+}
+
+func mustIssueToken(t *testing.T, secret []byte, claims TokenClaims) string {
+	t.Helper()
+	token, err := issueToken(secret, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
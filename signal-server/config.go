@@ -0,0 +1,292 @@
+// config.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Config 定义信令服务器的配置，优先级：命令行参数 > 环境变量 > 配置文件 > 默认值
+type Config struct {
+	ListenAddr      string     `json:"listen_addr"`       // 监听地址，例如 ":8080"
+	TLSCertFile     string     `json:"tls_cert_file"`     // TLS 证书，与 tls_key_file 同时设置时提供 wss://
+	TLSKeyFile      string     `json:"tls_key_file"`      // TLS 私钥
+	AllowedOrigins  []string   `json:"allowed_origins"`   // 允许的浏览器来源，"*" 表示全部允许，为空时只允许同源
+	ReadLimit       int64      `json:"read_limit"`        // 单条 WebSocket 消息的最大字节数
+	WriteTimeout    Duration   `json:"write_timeout"`     // 单次 WebSocket 写操作的超时时间
+	PingInterval    Duration   `json:"ping_interval"`     // 向客户端发送 WebSocket Ping 的间隔
+	PongTimeout     Duration   `json:"pong_timeout"`      // 超过该时间未收到 Pong 或消息即视为断开
+	SendQueueSize   int        `json:"send_queue_size"`   // 每个客户端出站队列的最大消息数
+	SendQueuePolicy string     `json:"send_queue_policy"` // 队列已满时的策略："drop"、"disconnect" 或 "coalesce"
+	MetricsAddr     string     `json:"metrics_addr"`      // /metrics 监听地址，为空时不启用
+	AuthSecret      string     `json:"auth_secret"`       // 校验注册令牌的 HMAC 密钥
+	NoAuth          bool       `json:"no_auth"`           // 不校验注册令牌，仅用于本地调试
+	TURN            TURNConfig `json:"turn"`
+}
+
+// TURNConfig 定义 TURN 凭证签发及内置 TURN/STUN 服务器的配置
+type TURNConfig struct {
+	URLs     []string `json:"urls"`      // 下发给客户端的 TURN 地址，为空且启用内置服务器时自动生成
+	Secret   string   `json:"secret"`    // 与 coturn static-auth-secret 相同的密钥
+	TTL      Duration `json:"ttl"`       // 临时凭证有效期
+	Listen   string   `json:"listen"`    // 内置 TURN/STUN 服务器监听地址，为空时不启用
+	PublicIP string   `json:"public_ip"` // 内置服务器对外公布的 IP 地址
+	Realm    string   `json:"realm"`
+	MinPort  int      `json:"min_port"` // 中继端口范围下限
+	MaxPort  int      `json:"max_port"` // 中继端口范围上限（含）
+}
+
+// Duration 支持在 JSON 中以 "30s"、"12h" 形式书写的时间间隔
+type Duration time.Duration
+
+// UnmarshalJSON 解析 time.ParseDuration 格式的字符串
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON 以 time.Duration 的字符串形式输出
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// defaultConfig 返回默认配置
+func defaultConfig() *Config {
+	return &Config{
+		ListenAddr:      ":8080",
+		ReadLimit:       64 * 1024,
+		WriteTimeout:    Duration(10 * time.Second),
+		PingInterval:    Duration(20 * time.Second),
+		PongTimeout:     Duration(45 * time.Second),
+		SendQueueSize:   64,
+		SendQueuePolicy: overflowCoalesce,
+		TURN: TURNConfig{
+			TTL:     Duration(12 * time.Hour),
+			Realm:   "webrtc.com",
+			MinPort: 49160,
+			MaxPort: 49200,
+		},
+	}
+}
+
+// loadConfig 依次加载默认值、配置文件、环境变量和命令行参数，并校验最终配置
+func loadConfig(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("signal-server", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("SIGNAL_CONFIG"), "JSON 配置文件路径（默认读取 SIGNAL_CONFIG）")
+	listenAddr := fs.String("listen", "", "监听地址，例如 :8080")
+	tlsCert := fs.String("tls-cert", "", "TLS 证书文件，与 -tls-key 同时设置时提供 wss://")
+	tlsKey := fs.String("tls-key", "", "TLS 私钥文件")
+	allowedOrigins := fs.String("allowed-origins", "", "允许的浏览器来源，多个以逗号分隔，* 表示全部允许")
+	readLimit := fs.Int64("read-limit", 0, "单条 WebSocket 消息的最大字节数")
+	writeTimeout := fs.Duration("write-timeout", 0, "单次 WebSocket 写操作的超时时间")
+	pingInterval := fs.Duration("ping-interval", 0, "向客户端发送 WebSocket Ping 的间隔")
+	pongTimeout := fs.Duration("pong-timeout", 0, "超过该时间未收到 Pong 或消息即视为断开")
+	sendQueueSize := fs.Int("send-queue-size", 0, "每个客户端出站队列的最大消息数")
+	sendQueuePolicy := fs.String("send-queue-policy", "", "队列已满时的策略：drop、disconnect 或 coalesce")
+	metricsAddr := fs.String("metrics-addr", "", "/metrics 监听地址，例如 127.0.0.1:9090")
+	authSecret := fs.String("auth-secret", "", "校验注册令牌的 HMAC 密钥")
+	noAuth := fs.Bool("no-auth", false, "不校验注册令牌，仅用于本地调试")
+	turnURLs := fs.String("turn-urls", "", "下发给客户端的 TURN 地址，多个以逗号分隔，例如 turn:192.168.40.100:23478")
+	turnSecret := fs.String("turn-secret", "", "与 coturn static-auth-secret 相同的密钥")
+	turnTTL := fs.Duration("turn-ttl", 0, "TURN 临时凭证有效期")
+	turnListen := fs.String("turn-listen", "", "启用内置 TURN/STUN 服务器的监听地址，例如 :3478")
+	turnPublicIP := fs.String("turn-public-ip", "", "内置 TURN 服务器对外公布的 IP 地址")
+	turnRealm := fs.String("turn-realm", "", "内置 TURN 服务器的 realm")
+	turnMinPort := fs.Int("turn-min-port", 0, "内置 TURN 服务器中继端口范围下限")
+	turnMaxPort := fs.Int("turn-max-port", 0, "内置 TURN 服务器中继端口范围上限")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := defaultConfig()
+	if *configPath != "" {
+		data, err := os.ReadFile(*configPath)
+		if err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parse config file %s: %w", *configPath, err)
+		}
+	}
+
+	applyEnv(cfg)
+
+	// 只覆盖命令行中显式指定的参数
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "listen":
+			cfg.ListenAddr = *listenAddr
+		case "tls-cert":
+			cfg.TLSCertFile = *tlsCert
+		case "tls-key":
+			cfg.TLSKeyFile = *tlsKey
+		case "allowed-origins":
+			cfg.AllowedOrigins = splitList(*allowedOrigins)
+		case "read-limit":
+			cfg.ReadLimit = *readLimit
+		case "write-timeout":
+			cfg.WriteTimeout = Duration(*writeTimeout)
+		case "ping-interval":
+			cfg.PingInterval = Duration(*pingInterval)
+		case "pong-timeout":
+			cfg.PongTimeout = Duration(*pongTimeout)
+		case "send-queue-size":
+			cfg.SendQueueSize = *sendQueueSize
+		case "send-queue-policy":
+			cfg.SendQueuePolicy = *sendQueuePolicy
+		case "metrics-addr":
+			cfg.MetricsAddr = *metricsAddr
+		case "auth-secret":
+			cfg.AuthSecret = *authSecret
+		case "no-auth":
+			cfg.NoAuth = *noAuth
+		case "turn-urls":
+			cfg.TURN.URLs = splitList(*turnURLs)
+		case "turn-secret":
+			cfg.TURN.Secret = *turnSecret
+		case "turn-ttl":
+			cfg.TURN.TTL = Duration(*turnTTL)
+		case "turn-listen":
+			cfg.TURN.Listen = *turnListen
+		case "turn-public-ip":
+			cfg.TURN.PublicIP = *turnPublicIP
+		case "turn-realm":
+			cfg.TURN.Realm = *turnRealm
+		case "turn-min-port":
+			cfg.TURN.MinPort = *turnMinPort
+		case "turn-max-port":
+			cfg.TURN.MaxPort = *turnMaxPort
+		}
+	})
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv 使用环境变量覆盖配置，密钥类参数推荐通过环境变量传入
+func applyEnv(cfg *Config) {
+	stringVars := map[string]*string{
+		"SIGNAL_LISTEN_ADDR":      &cfg.ListenAddr,
+		"SIGNAL_TLS_CERT":         &cfg.TLSCertFile,
+		"SIGNAL_TLS_KEY":          &cfg.TLSKeyFile,
+		"SIGNAL_AUTH_SECRET":      &cfg.AuthSecret,
+		"TURN_STATIC_AUTH_SECRET": &cfg.TURN.Secret,
+	}
+	for name, field := range stringVars {
+		if v, ok := os.LookupEnv(name); ok {
+			*field = v
+		}
+	}
+	if v, ok := os.LookupEnv("SIGNAL_ALLOWED_ORIGINS"); ok {
+		cfg.AllowedOrigins = splitList(v)
+	}
+}
+
+// validate 校验配置，返回所有不合法的字段
+func (c *Config) validate() error {
+	var errs []error
+	if c.ListenAddr == "" {
+		errs = append(errs, errors.New("listen_addr must not be empty"))
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		errs = append(errs, errors.New("tls_cert_file and tls_key_file must be set together"))
+	}
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		u, err := url.Parse(origin)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			errs = append(errs, fmt.Errorf("allowed_origins entry %q must look like https://host[:port]", origin))
+		}
+	}
+	if c.ReadLimit <= 0 {
+		errs = append(errs, fmt.Errorf("read_limit %d must be positive", c.ReadLimit))
+	}
+	if c.WriteTimeout <= 0 {
+		errs = append(errs, errors.New("write_timeout must be positive"))
+	}
+	if c.PingInterval <= 0 {
+		errs = append(errs, errors.New("ping_interval must be positive"))
+	}
+	if c.PongTimeout <= c.PingInterval {
+		errs = append(errs, errors.New("pong_timeout must be longer than ping_interval"))
+	}
+	if c.SendQueueSize < 1 {
+		errs = append(errs, fmt.Errorf("send_queue_size %d must be positive", c.SendQueueSize))
+	}
+	switch c.SendQueuePolicy {
+	case overflowDrop, overflowDisconnect, overflowCoalesce:
+	default:
+		errs = append(errs, fmt.Errorf("send_queue_policy %q must be drop, disconnect or coalesce", c.SendQueuePolicy))
+	}
+	if !c.NoAuth && c.AuthSecret == "" {
+		errs = append(errs, errors.New("auth_secret (or SIGNAL_AUTH_SECRET) is required unless no_auth is set"))
+	}
+	if c.TURN.TTL <= 0 {
+		errs = append(errs, errors.New("turn.ttl must be positive"))
+	}
+	if c.TURN.Secret == turnSecretPlaceholder {
+		errs = append(errs, fmt.Errorf("turn.secret must not be the placeholder %q, use the random string from static-auth-secret", turnSecretPlaceholder))
+	}
+	if c.TURN.Listen != "" {
+		if c.TURN.Secret == "" {
+			errs = append(errs, errors.New("turn.secret is required when turn.listen is set"))
+		}
+		if net.ParseIP(c.TURN.PublicIP) == nil {
+			errs = append(errs, fmt.Errorf("turn.public_ip %q must be a valid IP when turn.listen is set", c.TURN.PublicIP))
+		}
+		if c.TURN.MinPort < 1 || c.TURN.MaxPort > 65535 || c.TURN.MaxPort < c.TURN.MinPort {
+			errs = append(errs, fmt.Errorf("turn port range %d-%d is invalid", c.TURN.MinPort, c.TURN.MaxPort))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkOrigin 根据 allowed_origins 校验 WebSocket 握手请求的来源。
+// 不带 Origin 头的请求（如桌面客户端）直接放行，列表为空时只允许同源。
+func (c *Config) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if len(c.AllowedOrigins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// splitList 将逗号分隔的字符串拆分为列表，忽略空项
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
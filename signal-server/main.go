@@ -3,9 +3,14 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"flag"
 	"log"
+	"net"
 	"net/http"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/pion/webrtc/v3"
@@ -13,30 +18,116 @@ import (
 
 // Message 定义信令服务器与客户端之间的消息格式
 type Message struct {
-	Type    string          `json:"type"`    // "register", "offer", "answer", "candidate", "control_command"
-	Payload json.RawMessage `json:"payload"` // SDP 信息、ICE 候选或控制指令
+	Type     string          `json:"type"`                // "register", "offer", "answer", "candidate", "control_command", "session_rejected"
+	ViewerID string          `json:"viewer_id,omitempty"` // 消息关联的 Viewer，由服务器在转发时填写
+	ViewOnly bool            `json:"view_only,omitempty"` // 发送消息的 Viewer 只能观看，由服务器根据其令牌在转发时填写
+	Subject  string          `json:"subject,omitempty"`   // 发送消息的 Viewer 的令牌持有者，由服务器在转发时填写
+	Payload  json.RawMessage `json:"payload"`             // SDP 信息、ICE 候选或控制指令
 }
 
 // Client 代表一个连接的客户端
 type Client struct {
 	conn        *websocket.Conn
-	send        chan []byte
-	role        string // "viewer" 或 "desktop"
+	queue       *sendQueue // 有界出站队列，由写协程发送
+	id          string     // 注册时分配的唯一 ID，Viewer 用它区分各自的 PeerConnection
+	role        string     // "viewer" 或 "desktop"
+	viewOnly    bool       // Viewer 的令牌只允许观看
+	subject     string     // 令牌持有者，未启用令牌校验时为空
+	room        *Room      // 所属会话，注册成功前为 nil
 	peerConn    *webrtc.PeerConnection
 	dataChannel *webrtc.DataChannel
 }
 
+// label 返回用于日志的客户端标识：注册后为分配的 ID，注册前为远端地址
+func (c *Client) label() string {
+	if c.id != "" {
+		return c.id
+	}
+	return c.conn.RemoteAddr().String()
+}
+
 var (
-	upgrader      = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
-	viewerClient  *Client
-	desktopClient *Client
-	mutex         = &sync.Mutex{}
+	upgrader = websocket.Upgrader{} // CheckOrigin 在启动时根据 allowed_origins 设置
+	config   *Config                // 启动时加载，之后只读
+	mutex    = &sync.Mutex{}
 )
 
 func main() {
+	// 子命令 token 用于签发注册令牌
+	if len(os.Args) > 1 && os.Args[1] == "token" {
+		runTokenCommand(os.Args[2:])
+		return
+	}
+
+	var err error
+	config, err = loadConfig(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+	upgrader.CheckOrigin = config.checkOrigin
+
+	if config.NoAuth {
+		log.Println("WARNING: token authentication disabled, anyone can register.")
+	} else {
+		authSecret = []byte(config.AuthSecret)
+	}
+
+	turnURLs = config.TURN.URLs
+	if config.TURN.Secret != "" {
+		turnSecret = []byte(config.TURN.Secret)
+	}
+	turnCredentialTTL = time.Duration(config.TURN.TTL)
+
+	if config.TURN.Listen != "" {
+		turnConfig := EmbeddedTURNConfig{
+			ListenAddr: config.TURN.Listen,
+			PublicIP:   net.ParseIP(config.TURN.PublicIP),
+			Realm:      config.TURN.Realm,
+			MinPort:    uint16(config.TURN.MinPort),
+			MaxPort:    uint16(config.TURN.MaxPort),
+		}
+		turnServer, err := startEmbeddedTURN(turnConfig)
+		if err != nil {
+			log.Fatal("Start embedded TURN server failed:", err)
+		}
+		defer turnServer.Close()
+		// 未显式指定 TURN 地址时向客户端公布内置服务器地址
+		if len(turnURLs) == 0 {
+			turnURLs, err = embeddedTURNURLs(turnConfig)
+			if err != nil {
+				log.Fatal("Invalid turn listen address:", err)
+			}
+		}
+		log.Printf("Embedded TURN/STUN server started on %s, relay ports %d-%d\n", config.TURN.Listen, config.TURN.MinPort, config.TURN.MaxPort)
+	}
+
 	http.HandleFunc("/ws", handleConnections)
-	log.Println("Signaling server started on :8080")
-	err := http.ListenAndServe(":8080", nil)
+	if config.MetricsAddr != "" {
+		// 指标单独监听，避免会话 ID 暴露在公网端口上
+		metricsMux := http.NewServeMux()
+		metricsMux.HandleFunc("/metrics", handleMetrics)
+		go func() {
+			log.Printf("Metrics available on %s/metrics\n", config.MetricsAddr)
+			err := http.ListenAndServe(config.MetricsAddr, metricsMux)
+			if err != nil {
+				log.Println("Metrics server failed:", err)
+			}
+		}()
+	}
+	server := &http.Server{
+		Addr:              config.ListenAddr,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if config.TLSCertFile != "" {
+		log.Printf("Signaling server started on %s (wss)\n", config.ListenAddr)
+		err = server.ListenAndServeTLS(config.TLSCertFile, config.TLSKeyFile)
+	} else {
+		log.Printf("Signaling server started on %s\n", config.ListenAddr)
+		err = server.ListenAndServe()
+	}
 	if err != nil {
 		log.Fatal("ListenAndServe failed:", err)
 	}
@@ -49,7 +140,8 @@ func handleConnections(w http.ResponseWriter, r *http.Request) {
 		log.Println("WebSocket upgrade failed:", err)
 		return
 	}
-	client := &Client{conn: conn, send: make(chan []byte)}
+	conn.SetReadLimit(config.ReadLimit)
+	client := &Client{conn: conn, queue: newSendQueue(config.SendQueueSize, config.SendQueuePolicy)}
 	go handleClient(client)
 }
 
@@ -57,27 +149,63 @@ func handleConnections(w http.ResponseWriter, r *http.Request) {
 func handleClient(client *Client) {
 	defer func() {
 		client.conn.Close()
+		client.queue.close()
 		mutex.Lock()
 		defer mutex.Unlock()
+		room := client.room
+		if room == nil {
+			return
+		}
+		room.removeClient(client)
 		if client.role == "viewer" {
-			log.Println("Viewer client disconnected.")
-			viewerClient = nil
-			// 通知 Desktop 连接已断开
-			notifyDesktop("desktop_disconnected", nil)
+			log.Printf("Viewer client %s disconnected from session %s.\n", client.id, room.id)
+			// 通知同一会话中的 Desktop 释放该 Viewer 的 PeerConnection
+			notifyDesktop(room, "viewer_disconnected", map[string]string{"viewer_id": client.id})
 		} else if client.role == "desktop" {
-			log.Println("Desktop client disconnected.")
-			desktopClient = nil
-			// 通知 Viewer 连接已断开
-			notifyViewer("desktop_disconnected", nil)
+			log.Printf("Desktop client disconnected from session %s.\n", room.id)
+			// 通知同一会话中的所有 Viewer 连接已断开
+			notifyViewers(room, "desktop_disconnected", nil)
 		}
 	}()
 
-	// 启动一个协程来发送消息
+	// 心跳：每次收到 Pong 或消息都延长读超时，超时未收到时 ReadMessage 返回错误并释放会话槽位
+	pongTimeout := time.Duration(config.PongTimeout)
+	client.conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	client.conn.SetPongHandler(func(string) error {
+		return client.conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	})
+
+	// 启动一个协程来发送消息和定时 Ping
+	done := make(chan struct{})
+	defer close(done)
 	go func() {
-		for msg := range client.send {
-			err := client.conn.WriteMessage(websocket.TextMessage, msg)
-			if err != nil {
-				log.Println("Write message failed:", err)
+		ticker := time.NewTicker(time.Duration(config.PingInterval))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-client.queue.notify:
+				for _, message := range client.queue.popAll() {
+					msg, err := json.Marshal(message)
+					if err != nil {
+						log.Println("Marshal message failed:", err)
+						continue
+					}
+					client.conn.SetWriteDeadline(time.Now().Add(time.Duration(config.WriteTimeout)))
+					err = client.conn.WriteMessage(websocket.TextMessage, msg)
+					if err != nil {
+						log.Println("Write message failed:", err)
+						client.conn.Close()
+						return
+					}
+				}
+			case <-ticker.C:
+				err := client.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Duration(config.WriteTimeout)))
+				if err != nil {
+					log.Println("Write ping failed:", err)
+					client.conn.Close()
+					return
+				}
+			case <-done:
 				return
 			}
 		}
@@ -89,8 +217,7 @@ func handleClient(client *Client) {
 			log.Println("Read message failed:", err)
 			break
 		}
-
-		log.Printf("Received raw message: %s\n", string(msg))
+		client.conn.SetReadDeadline(time.Now().Add(pongTimeout))
 
 		var message Message
 		err = json.Unmarshal(msg, &message)
@@ -99,19 +226,22 @@ func handleClient(client *Client) {
 			continue
 		}
 
-		log.Printf("Parsed message type: %s\n", message.Type)
+		// 只记录消息类型和客户端，注册消息中带有令牌，SDP 和候选中带有 ICE 凭证，不能写入日志
+		log.Printf("Received %s message from client %s.\n", message.Type, client.label())
 
 		switch message.Type {
 		case "register":
 			handleRegister(client, message.Payload)
 		case "offer":
-			handleOffer(client, message.Payload)
+			handleOffer(client, message.ViewerID, message.Payload)
 		case "answer":
-			handleAnswer(client, message.Payload)
+			handleAnswer(client, message.ViewerID, message.Payload)
 		case "candidate":
-			handleCandidate(client, message.Payload)
+			handleCandidate(client, message.ViewerID, message.Payload)
 		case "control_command":
-			handleControlCommand(client, message.Payload)
+			handleControlCommand(client, message.ViewerID, message.Payload)
+		case "session_rejected":
+			handleSessionRejected(client, message.ViewerID, message.Payload)
 		default:
 			log.Println("Unknown message type:", message.Type)
 		}
@@ -121,7 +251,10 @@ func handleClient(client *Client) {
 // handleRegister 处理注册消息
 func handleRegister(client *Client, payload json.RawMessage) {
 	var data struct {
-		Role string `json:"role"` // "viewer" 或 "desktop"
+		Role      string `json:"role"`       // "viewer" 或 "desktop"
+		SessionID string `json:"session_id"` // 要加入的会话 ID，为空时使用令牌授予的会话
+		Token     string `json:"token"`      // 注册令牌
+		Resume    bool   `json:"resume"`     // Desktop 重连后将通过 ICE 重启恢复已有连接
 	}
 	err := json.Unmarshal(payload, &data)
 	if err != nil {
@@ -132,27 +265,68 @@ func handleRegister(client *Client, payload json.RawMessage) {
 	mutex.Lock()
 	defer mutex.Unlock()
 
+	if client.room != nil {
+		// 同一连接不允许重复注册
+		response := Message{
+			Type:    "register_failed",
+			Payload: json.RawMessage(`{"reason": "already_registered"}`),
+		}
+		sendMessage(client, response)
+		return
+	}
+
+	// 校验令牌授予的角色和会话
+	claims, err := authorizeRegister(data.Token, data.Role, data.SessionID)
+	if err != nil {
+		log.Printf("Register rejected: %v\n", err)
+		reason, _ := json.Marshal(map[string]string{"reason": err.Error()})
+		response := Message{
+			Type:    "register_failed",
+			Payload: json.RawMessage(reason),
+		}
+		sendMessage(client, response)
+		return
+	}
+	data.SessionID = claims.SessionID
+
+	if !validSessionID(data.SessionID) {
+		response := Message{
+			Type:    "register_failed",
+			Payload: json.RawMessage(`{"reason": "invalid_session"}`),
+		}
+		sendMessage(client, response)
+		return
+	}
+
 	if data.Role == "viewer" {
-		if viewerClient != nil {
-			// 已有 Viewer 连接，拒绝注册
-			response := Message{
-				Type:    "register_failed",
-				Payload: json.RawMessage(`{"reason": "viewer_already_exists"}`),
-			}
-			sendMessage(client, response)
+		viewerID, err := newClientID()
+		if err != nil {
+			log.Println("Generate viewer ID failed:", err)
 			return
 		}
+		room := getOrCreateRoom(data.SessionID)
+		client.id = viewerID
 		client.role = "viewer"
-		viewerClient = client
+		client.viewOnly = claims.ViewOnly
+		client.subject = claims.Subject
+		client.room = room
+		room.viewers[viewerID] = client
+		successPayload, _ := json.Marshal(map[string]interface{}{
+			"role":        "viewer",
+			"viewer_id":   viewerID,
+			"view_only":   claims.ViewOnly,
+			"ice_servers": iceServersFor(room.id, "viewer"),
+		})
 		response := Message{
 			Type:    "register_success",
-			Payload: json.RawMessage(`{"role": "viewer"}`),
+			Payload: json.RawMessage(successPayload),
 		}
 		sendMessage(client, response)
-		log.Println("Viewer client registered.")
+		log.Printf("Viewer client %s registered in session %s (subject %q, view only: %t).\n", viewerID, room.id, claims.Subject, claims.ViewOnly)
 	} else if data.Role == "desktop" {
-		if desktopClient != nil {
-			// 已有 Desktop 连接，拒绝注册
+		room := getOrCreateRoom(data.SessionID)
+		if room.desktop != nil {
+			// 该会话已有 Desktop 连接，拒绝注册
 			response := Message{
 				Type:    "register_failed",
 				Payload: json.RawMessage(`{"reason": "desktop_already_exists"}`),
@@ -161,13 +335,20 @@ func handleRegister(client *Client, payload json.RawMessage) {
 			return
 		}
 		client.role = "desktop"
-		desktopClient = client
+		client.room = room
+		room.desktop = client
+		successPayload, _ := json.Marshal(map[string]interface{}{
+			"role":        "desktop",
+			"ice_servers": iceServersFor(room.id, "desktop"),
+		})
 		response := Message{
 			Type:    "register_success",
-			Payload: json.RawMessage(`{"role": "desktop"}`),
+			Payload: json.RawMessage(successPayload),
 		}
 		sendMessage(client, response)
-		log.Println("Desktop client registered.")
+		log.Printf("Desktop client registered in session %s.\n", room.id)
+		// 通知已在会话中的 Viewer：resume 为 true 时保留现有连接等待 ICE 重启，否则重新发起 Offer
+		notifyViewers(room, "desktop_connected", map[string]bool{"resume": data.Resume})
 	} else {
 		// 无效角色
 		response := Message{
@@ -179,192 +360,79 @@ func handleRegister(client *Client, payload json.RawMessage) {
 }
 
 // handleOffer 处理来自 Viewer 或 Desktop 的 Offer 并转发
-func handleOffer(client *Client, payload json.RawMessage) {
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	if client.role != "viewer" && client.role != "desktop" {
-		log.Println("Unknown role attempted to send offer, ignoring.")
-		return
-	}
-
-	// 根据角色确定接收方
-	var forwardClient *Client
-	if client.role == "viewer" {
-		if desktopClient == nil {
-			log.Println("No desktop client connected, cannot forward offer.")
-			return
-		}
-		forwardClient = desktopClient
-	} else if client.role == "desktop" {
-		if viewerClient == nil {
-			log.Println("No viewer client connected, cannot forward offer.")
-			return
-		}
-		forwardClient = viewerClient
-	}
-
-	log.Printf("Forwarding offer payload to %s: %s\n", forwardClient.role, string(payload))
-
-	// 封装 payload 为新的 Message
-	forwardMsg := Message{
-		Type:    "offer",
-		Payload: payload,
-	}
-
-	// 序列化新的 Message
-	forwardBytes, err := json.Marshal(forwardMsg)
-	if err != nil {
-		log.Println("Failed to marshal offer message:", err)
-		return
-	}
-
-	// 转发 Offer
-	forwardClient.send <- forwardBytes
-	log.Printf("Forwarded offer from %s to %s.\n", client.role, forwardClient.role)
+func handleOffer(client *Client, viewerID string, payload json.RawMessage) {
+	forwardMessage(client, "offer", viewerID, payload)
 }
 
 // handleAnswer 处理来自 Viewer 或 Desktop 的 Answer 并转发
-func handleAnswer(client *Client, payload json.RawMessage) {
-	mutex.Lock()
-	defer mutex.Unlock()
-
-	if client.role != "viewer" && client.role != "desktop" {
-		log.Println("Unknown role attempted to send answer, ignoring.")
-		return
-	}
-
-	// 根据角色确定接收方
-	var forwardClient *Client
-	if client.role == "viewer" {
-		if desktopClient == nil {
-			log.Println("No desktop client connected, cannot forward answer.")
-			return
-		}
-		forwardClient = desktopClient
-	} else if client.role == "desktop" {
-		if viewerClient == nil {
-			log.Println("No viewer client connected, cannot forward answer.")
-			return
-		}
-		forwardClient = viewerClient
-	}
-
-	log.Printf("Forwarding answer payload to %s: %s\n", forwardClient.role, string(payload))
-
-	// 封装 payload 为新的 Message
-	forwardMsg := Message{
-		Type:    "answer",
-		Payload: payload,
-	}
-
-	// 序列化新的 Message
-	forwardBytes, err := json.Marshal(forwardMsg)
-	if err != nil {
-		log.Println("Failed to marshal answer message:", err)
-		return
-	}
-
-	// 转发 Answer
-	forwardClient.send <- forwardBytes
-	log.Printf("Forwarded answer from %s to %s.\n", client.role, forwardClient.role)
+func handleAnswer(client *Client, viewerID string, payload json.RawMessage) {
+	forwardMessage(client, "answer", viewerID, payload)
 }
 
 // handleCandidate 处理来自 Viewer 或 Desktop 的 ICE Candidate 并转发
-func handleCandidate(client *Client, payload json.RawMessage) {
-	mutex.Lock()
-	defer mutex.Unlock()
+func handleCandidate(client *Client, viewerID string, payload json.RawMessage) {
+	forwardMessage(client, "candidate", viewerID, payload)
+}
 
-	if client.role != "viewer" && client.role != "desktop" {
-		log.Println("Unknown role attempted to send ICE candidate, ignoring.")
+// handleControlCommand 处理来自 Viewer 的控制指令并转发给 Desktop，只能观看的 Viewer 发送的指令被丢弃
+func handleControlCommand(client *Client, viewerID string, payload json.RawMessage) {
+	mutex.Lock()
+	viewOnly := client.role == "viewer" && client.viewOnly
+	mutex.Unlock()
+	if viewOnly {
+		log.Printf("View-only viewer %s attempted to send control_command, ignoring.\n", client.id)
 		return
 	}
+	forwardMessage(client, "control_command", viewerID, payload)
+}
 
-	// 根据角色确定接收方
-	var forwardClient *Client
-	if client.role == "viewer" {
-		if desktopClient == nil {
-			log.Println("No desktop client connected, cannot forward ICE candidate.")
-			return
-		}
-		forwardClient = desktopClient
-	} else if client.role == "desktop" {
-		if viewerClient == nil {
-			log.Println("No viewer client connected, cannot forward ICE candidate.")
-			return
-		}
-		forwardClient = viewerClient
-	}
-
-	log.Printf("Forwarding ICE candidate from %s to %s: %s\n", client.role, forwardClient.role, string(payload))
-
-	// 封装payload为正确格式
-	forwardMsg := Message{
-		Type:    "candidate",
-		Payload: payload,
-	}
-
-	// 序列化新的 Message
-	forwardBytes, err := json.Marshal(forwardMsg)
-	if err != nil {
-		log.Println("Failed to marshal candidate message:", err)
+// handleSessionRejected 将 Desktop 拒绝连接的通知转发给对应的 Viewer
+func handleSessionRejected(client *Client, viewerID string, payload json.RawMessage) {
+	if client.role != "desktop" {
+		log.Printf("Client %s with role %q attempted to send session_rejected, ignoring.\n", client.id, client.role)
 		return
 	}
-
-	// 转发 ICE Candidate
-	forwardClient.send <- forwardBytes
-	log.Printf("Forwarded ICE candidate from %s to %s.\n", client.role, forwardClient.role)
+	forwardMessage(client, "session_rejected", viewerID, payload)
 }
 
-// handleControlCommand 处理来自 Viewer 的控制指令并转发给 Desktop
-func handleControlCommand(client *Client, payload json.RawMessage) {
+// forwardMessage 在同一会话内将消息转发给对端。
+// 只在查找接收方时持有 mutex，消息放入接收方的发送队列后由其写协程发送。
+func forwardMessage(client *Client, msgType, viewerID string, payload json.RawMessage) {
 	mutex.Lock()
-	defer mutex.Unlock()
-
-	if client.role != "viewer" && client.role != "desktop" {
-		log.Println("Unknown role attempted to send control command, ignoring.")
+	if client.room == nil || (client.role != "viewer" && client.role != "desktop") {
+		mutex.Unlock()
+		log.Printf("Unregistered client attempted to send %s, ignoring.\n", msgType)
 		return
 	}
 
-	// 根据角色确定接收方
-	var forwardClient *Client
+	// 在同一会话内确定接收方
+	forwardClient, viewerID := client.room.route(client, viewerID)
+	roomID := client.room.id
+	var viewOnly bool
+	var subject string
 	if client.role == "viewer" {
-		if desktopClient == nil {
-			log.Println("No desktop client connected, cannot forward control command.")
-			return
-		}
-		forwardClient = desktopClient
-	} else if client.role == "desktop" {
-		if viewerClient == nil {
-			log.Println("No viewer client connected, cannot forward control command.")
-			return
-		}
-		forwardClient = viewerClient
-	}
-
-	log.Printf("Forwarding control command from %s to %s: %s\n", client.role, forwardClient.role, string(payload))
-
-	// 封装 payload 为新的 Message
-	forwardMsg := Message{
-		Type:    "control_command",
-		Payload: payload,
+		viewOnly, subject = client.viewOnly, client.subject
 	}
-
-	// 序列化新的 Message
-	forwardBytes, err := json.Marshal(forwardMsg)
-	if err != nil {
-		log.Println("Failed to marshal control_command message:", err)
+	mutex.Unlock()
+	if forwardClient == nil {
+		log.Printf("No peer connected in session %s, cannot forward %s.\n", roomID, msgType)
 		return
 	}
 
-	// 转发 Control Command
-	forwardClient.send <- forwardBytes
-	log.Printf("Forwarded control command from %s to %s.\n", client.role, forwardClient.role)
+	// 封装 payload 为新的 Message 并放入接收方的发送队列
+	enqueue(forwardClient, Message{
+		Type:     msgType,
+		ViewerID: viewerID,
+		ViewOnly: viewOnly,
+		Subject:  subject,
+		Payload:  payload,
+	})
+	log.Printf("Forwarded %s from %s to %s (viewer %s).\n", msgType, client.role, forwardClient.role, viewerID)
 }
 
-// notifyDesktop 通知 Desktop 客户端
-func notifyDesktop(msgType string, payload interface{}) {
-	if desktopClient == nil {
+// notifyDesktop 通知指定会话中的 Desktop 客户端
+func notifyDesktop(room *Room, msgType string, payload interface{}) {
+	if room.desktop == nil {
 		return
 	}
 
@@ -384,12 +452,12 @@ func notifyDesktop(msgType string, payload interface{}) {
 		Type:    msgType,
 		Payload: payloadBytes,
 	}
-	sendMessage(desktopClient, message)
+	sendMessage(room.desktop, message)
 }
 
-// notifyViewer 通知 Viewer 客户端
-func notifyViewer(msgType string, payload interface{}) {
-	if viewerClient == nil {
+// notifyViewers 通知指定会话中的所有 Viewer 客户端
+func notifyViewers(room *Room, msgType string, payload interface{}) {
+	if len(room.viewers) == 0 {
 		return
 	}
 
@@ -409,15 +477,12 @@ func notifyViewer(msgType string, payload interface{}) {
 		Type:    msgType,
 		Payload: payloadBytes,
 	}
-	sendMessage(viewerClient, message)
+	for _, viewer := range room.viewers {
+		sendMessage(viewer, message)
+	}
 }
 
-// sendMessage 发送消息给指定客户端
+// sendMessage 将消息放入指定客户端的发送队列，不会阻塞
 func sendMessage(client *Client, message Message) {
-	msg, err := json.Marshal(message)
-	if err != nil {
-		log.Println("Marshal message failed:", err)
-		return
-	}
-	client.send <- msg
+	enqueue(client, message)
 }
@@ -0,0 +1,207 @@
+// queue.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// 发送队列溢出策略
+const (
+	overflowDrop       = "drop"       // 丢弃新消息
+	overflowDisconnect = "disconnect" // 断开接收方，由其重连后重新协商
+	overflowCoalesce   = "coalesce"   // 合并同一 Viewer 排队中的 ICE 候选，仍放不下时断开
+)
+
+// 发送队列相关的累计指标，由 /metrics 输出
+var (
+	sendDroppedTotal    atomic.Uint64 // 因队列已满被丢弃的消息数
+	sendCoalescedTotal  atomic.Uint64 // 被合并掉的 ICE 候选消息数
+	slowDisconnectTotal atomic.Uint64 // 因队列已满被断开的客户端数
+)
+
+// sendQueue 是每个客户端有界的出站消息队列。
+// 入队不会阻塞，真正的 WebSocket 写操作由客户端自己的写协程完成，
+// 因此一个缓慢或失联的接收方不会拖住其他客户端的转发。
+type sendQueue struct {
+	mu        sync.Mutex
+	items     []Message
+	limit     int
+	policy    string
+	highWater int           // 队列深度的历史最大值
+	notify    chan struct{} // 有新消息时通知写协程，容量为 1
+	closed    bool
+}
+
+// newSendQueue 创建容量为 limit、使用指定溢出策略的发送队列
+func newSendQueue(limit int, policy string) *sendQueue {
+	return &sendQueue{
+		limit:  limit,
+		policy: policy,
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// push 将消息加入队列，队列已满时按溢出策略处理。
+// 返回 false 表示应断开接收方。
+func (q *sendQueue) push(message Message) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return true
+	}
+	if len(q.items) >= q.limit {
+		switch q.policy {
+		case overflowDrop:
+			sendDroppedTotal.Add(1)
+			return true
+		case overflowCoalesce:
+			if q.coalesceCandidates() == 0 || len(q.items) >= q.limit {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+
+	q.items = append(q.items, message)
+	if len(q.items) > q.highWater {
+		q.highWater = len(q.items)
+	}
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+	return true
+}
+
+// popAll 取出队列中的全部消息
+func (q *sendQueue) popAll() []Message {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := q.items
+	q.items = nil
+	return items
+}
+
+// close 丢弃剩余消息，之后入队的消息直接忽略
+func (q *sendQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.items = nil
+}
+
+// stats 返回当前队列深度和历史最大深度
+func (q *sendQueue) stats() (depth, highWater int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items), q.highWater
+}
+
+// coalesceCandidates 将同一 Viewer 排队中的多条 candidate 消息合并为一条 candidates 消息，
+// 其 payload 为按原顺序排列的候选数组，返回减少的消息数（调用方需持有 q.mu）。
+// 合并在副本上进行，消息数没有减少时队列保持原样，不会把单条 candidate 改写为 candidates
+func (q *sendQueue) coalesceCandidates() int {
+	batches := make(map[string][]json.RawMessage)
+	for _, item := range q.items {
+		switch item.Type {
+		case "candidate":
+			batches[item.ViewerID] = append(batches[item.ViewerID], item.Payload)
+		case "candidates":
+			var list []json.RawMessage
+			if err := json.Unmarshal(item.Payload, &list); err == nil {
+				batches[item.ViewerID] = append(batches[item.ViewerID], list...)
+			}
+		}
+	}
+
+	items := make([]Message, 0, len(q.items))
+	merged := make(map[string]bool)
+	for _, item := range q.items {
+		if item.Type != "candidate" && item.Type != "candidates" {
+			items = append(items, item)
+			continue
+		}
+		// 合并后的消息放在该 Viewer 第一条候选的位置，保证位于同一轮的 Offer/Answer 之后
+		if merged[item.ViewerID] {
+			continue
+		}
+		merged[item.ViewerID] = true
+		payload, err := json.Marshal(batches[item.ViewerID])
+		if err != nil {
+			items = append(items, item)
+			continue
+		}
+		items = append(items, Message{Type: "candidates", ViewerID: item.ViewerID, Payload: payload})
+	}
+	reduced := len(q.items) - len(items)
+	if reduced == 0 {
+		return 0
+	}
+	q.items = items
+	sendCoalescedTotal.Add(uint64(reduced))
+	return reduced
+}
+
+// enqueue 将消息放入客户端的发送队列，队列溢出且需要断开时关闭连接。
+// 不会阻塞，可以在持有 mutex 时调用。
+func enqueue(client *Client, message Message) {
+	if client.queue.push(message) {
+		return
+	}
+	slowDisconnectTotal.Add(1)
+	log.Printf("Send queue of %s %s is full, disconnecting.\n", client.role, client.id)
+	client.conn.Close()
+}
+
+// handleMetrics 以 Prometheus 文本格式输出各客户端的发送队列深度和累计指标
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	type queueStat struct {
+		session, role, id string
+		depth, highWater  int
+	}
+	var stats []queueStat
+	mutex.Lock()
+	for _, room := range rooms {
+		clients := make([]*Client, 0, len(room.viewers)+1)
+		if room.desktop != nil {
+			clients = append(clients, room.desktop)
+		}
+		for _, viewer := range room.viewers {
+			clients = append(clients, viewer)
+		}
+		for _, c := range clients {
+			depth, highWater := c.queue.stats()
+			stats = append(stats, queueStat{room.id, c.role, c.id, depth, highWater})
+		}
+	}
+	mutex.Unlock()
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].session != stats[j].session {
+			return stats[i].session < stats[j].session
+		}
+		return stats[i].id < stats[j].id
+	})
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(w, "# TYPE signal_send_queue_limit gauge\nsignal_send_queue_limit %d\n", config.SendQueueSize)
+	io.WriteString(w, "# TYPE signal_send_queue_depth gauge\n")
+	for _, s := range stats {
+		fmt.Fprintf(w, "signal_send_queue_depth{session=%q,role=%q,id=%q} %d\n", s.session, s.role, s.id, s.depth)
+	}
+	io.WriteString(w, "# TYPE signal_send_queue_high_water gauge\n")
+	for _, s := range stats {
+		fmt.Fprintf(w, "signal_send_queue_high_water{session=%q,role=%q,id=%q} %d\n", s.session, s.role, s.id, s.highWater)
+	}
+	fmt.Fprintf(w, "# TYPE signal_send_dropped_total counter\nsignal_send_dropped_total %d\n", sendDroppedTotal.Load())
+	fmt.Fprintf(w, "# TYPE signal_send_coalesced_total counter\nsignal_send_coalesced_total %d\n", sendCoalescedTotal.Load())
+	fmt.Fprintf(w, "# TYPE signal_slow_disconnect_total counter\nsignal_slow_disconnect_total %d\n", slowDisconnectTotal.Load())
+}
@@ -0,0 +1,135 @@
+// queue_test.go
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSendQueuePushOverflow(t *testing.T) {
+	offer := Message{Type: "offer", ViewerID: "v1", Payload: json.RawMessage(`{}`)}
+	candidate := func(viewerID, payload string) Message {
+		return Message{Type: "candidate", ViewerID: viewerID, Payload: json.RawMessage(payload)}
+	}
+
+	tests := []struct {
+		name      string
+		policy    string
+		queued    []Message
+		push      Message
+		wantOK    bool
+		wantTypes []string
+	}{
+		{
+			name:      "below limit",
+			policy:    overflowDisconnect,
+			queued:    []Message{offer},
+			push:      candidate("v1", `"a"`),
+			wantOK:    true,
+			wantTypes: []string{"offer", "candidate"},
+		},
+		{
+			name:      "drop keeps queue",
+			policy:    overflowDrop,
+			queued:    []Message{offer, offer, offer},
+			push:      candidate("v1", `"a"`),
+			wantOK:    true,
+			wantTypes: []string{"offer", "offer", "offer"},
+		},
+		{
+			name:      "disconnect",
+			policy:    overflowDisconnect,
+			queued:    []Message{offer, offer, offer},
+			push:      offer,
+			wantOK:    false,
+			wantTypes: []string{"offer", "offer", "offer"},
+		},
+		{
+			name:      "coalesce frees room",
+			policy:    overflowCoalesce,
+			queued:    []Message{offer, candidate("v1", `"a"`), candidate("v1", `"b"`)},
+			push:      candidate("v1", `"c"`),
+			wantOK:    true,
+			wantTypes: []string{"offer", "candidates", "candidate"},
+		},
+		{
+			name:      "coalesce without candidates disconnects",
+			policy:    overflowCoalesce,
+			queued:    []Message{offer, offer, offer},
+			push:      candidate("v1", `"a"`),
+			wantOK:    false,
+			wantTypes: []string{"offer", "offer", "offer"},
+		},
+		{
+			name:      "coalesce single candidate per viewer disconnects",
+			policy:    overflowCoalesce,
+			queued:    []Message{offer, candidate("v1", `"a"`), candidate("v2", `"b"`)},
+			push:      candidate("v1", `"c"`),
+			wantOK:    false,
+			wantTypes: []string{"offer", "candidate", "candidate"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q := newSendQueue(3, tt.policy)
+			for _, message := range tt.queued {
+				if !q.push(message) {
+					t.Fatal("push below limit failed")
+				}
+			}
+			if ok := q.push(tt.push); ok != tt.wantOK {
+				t.Errorf("push() = %t, want %t", ok, tt.wantOK)
+			}
+			var types []string
+			for _, message := range q.popAll() {
+				types = append(types, message.Type)
+			}
+			if !reflect.DeepEqual(types, tt.wantTypes) {
+				t.Errorf("queued types = %v, want %v", types, tt.wantTypes)
+			}
+		})
+	}
+}
+
+func TestSendQueueClosed(t *testing.T) {
+	q := newSendQueue(1, overflowDisconnect)
+	q.close()
+	if !q.push(Message{Type: "offer"}) {
+		t.Error("push() after close should be ignored, not disconnect")
+	}
+	if depth, _ := q.stats(); depth != 0 {
+		t.Errorf("depth after close = %d, want 0", depth)
+	}
+}
+
+func TestCoalesceCandidates(t *testing.T) {
+	q := newSendQueue(10, overflowCoalesce)
+	q.items = []Message{
+		{Type: "candidate", ViewerID: "v1", Payload: json.RawMessage(`"a"`)},
+		{Type: "offer", ViewerID: "v2", Payload: json.RawMessage(`{}`)},
+		{Type: "candidate", ViewerID: "v2", Payload: json.RawMessage(`"x"`)},
+		{Type: "candidates", ViewerID: "v1", Payload: json.RawMessage(`["b","c"]`)},
+		{Type: "candidate", ViewerID: "v1", Payload: json.RawMessage(`"d"`)},
+		{Type: "answer", ViewerID: "v1", Payload: json.RawMessage(`{}`)},
+	}
+
+	if reduced := q.coalesceCandidates(); reduced != 2 {
+		t.Errorf("coalesceCandidates() = %d, want 2", reduced)
+	}
+	want := []Message{
+		{Type: "candidates", ViewerID: "v1", Payload: json.RawMessage(`["a","b","c","d"]`)},
+		{Type: "offer", ViewerID: "v2", Payload: json.RawMessage(`{}`)},
+		{Type: "candidates", ViewerID: "v2", Payload: json.RawMessage(`["x"]`)},
+		{Type: "answer", ViewerID: "v1", Payload: json.RawMessage(`{}`)},
+	}
+	if len(q.items) != len(want) {
+		t.Fatalf("got %d messages, want %d", len(q.items), len(want))
+	}
+	for i, message := range q.items {
+		if message.Type != want[i].Type || message.ViewerID != want[i].ViewerID || string(message.Payload) != string(want[i].Payload) {
+			t.Errorf("message %d = %s %s %s, want %s %s %s", i,
+				message.Type, message.ViewerID, message.Payload, want[i].Type, want[i].ViewerID, want[i].Payload)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// room.go
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// maxSessionIDLength 会话 ID 的最大长度
+const maxSessionIDLength = 64
+
+// Room 代表一个远程桌面会话，每个会话拥有一个 Desktop 和若干 Viewer
+type Room struct {
+	id      string
+	desktop *Client
+	viewers map[string]*Client // 以 viewer ID 为键
+}
+
+// rooms 会话注册表，以会话 ID 为键，访问时需持有 mutex
+var rooms = make(map[string]*Room)
+
+// getOrCreateRoom 获取指定 ID 的会话，不存在时创建（调用方需持有 mutex）
+func getOrCreateRoom(id string) *Room {
+	room, ok := rooms[id]
+	if !ok {
+		room = &Room{id: id, viewers: make(map[string]*Client)}
+		rooms[id] = room
+	}
+	return room
+}
+
+// removeClient 将客户端从会话中移除，会话为空时从注册表删除（调用方需持有 mutex）
+func (r *Room) removeClient(client *Client) {
+	if r.desktop == client {
+		r.desktop = nil
+	}
+	if r.viewers[client.id] == client {
+		delete(r.viewers, client.id)
+	}
+	if r.desktop == nil && len(r.viewers) == 0 {
+		delete(rooms, r.id)
+	}
+}
+
+// route 在同一会话内确定消息的接收方和关联的 viewer ID（调用方需持有 mutex）。
+// Viewer 发出的消息发往 Desktop，并附带发送者自身的 viewer ID；
+// Desktop 发出的消息按 viewerID 发往对应的 Viewer。
+func (r *Room) route(from *Client, viewerID string) (*Client, string) {
+	if from.role == "viewer" {
+		return r.desktop, from.id
+	}
+	return r.viewers[viewerID], viewerID
+}
+
+// newClientID 生成随机的客户端 ID
+func newClientID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validSessionID 检查会话 ID 是否合法：非空、长度受限且只包含字母、数字、'-' 和 '_'
+func validSessionID(id string) bool {
+	if id == "" || len(id) > maxSessionIDLength {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
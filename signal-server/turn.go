@@ -0,0 +1,146 @@
+// turn.go
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pion/turn/v2"
+)
+
+// ICEServer 下发给客户端的 ICE 服务器信息，字段与浏览器的 RTCIceServer 一致
+type ICEServer struct {
+	URLs       []string `json:"urls"`
+	Username   string   `json:"username,omitempty"`
+	Credential string   `json:"credential,omitempty"`
+}
+
+// turnSecretPlaceholder 示例配置曾使用的占位密钥，使用它签发的凭证等同于公开，启动时拒绝
+const turnSecretPlaceholder = "change-me"
+
+var (
+	turnSecret        []byte        // 与 coturn static-auth-secret 相同的共享密钥，为 nil 时不签发 TURN 凭证
+	turnURLs          []string      // 下发给客户端的 TURN/STUN 地址
+	turnCredentialTTL time.Duration // TURN 凭证有效期
+)
+
+// turnCredentials 按 TURN REST API（coturn use-auth-secret）规则生成临时凭证：
+// 用户名为 "过期时间戳:用户标识"，密码为 base64(HMAC-SHA1(secret, 用户名))
+func turnCredentials(secret []byte, user string, ttl time.Duration, now time.Time) (string, string) {
+	username := fmt.Sprintf("%d:%s", now.Add(ttl).Unix(), user)
+	return username, turnPassword(secret, username)
+}
+
+// turnPassword 计算临时凭证用户名对应的密码
+func turnPassword(secret []byte, username string) string {
+	mac := hmac.New(sha1.New, secret)
+	mac.Write([]byte(username))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// iceServersFor 为注册成功的客户端生成 ICE 服务器列表
+func iceServersFor(sessionID, role string) []ICEServer {
+	if len(turnURLs) == 0 {
+		return []ICEServer{}
+	}
+	if turnSecret == nil {
+		return []ICEServer{{URLs: turnURLs}}
+	}
+	username, password := turnCredentials(turnSecret, sessionID+"_"+role, turnCredentialTTL, time.Now())
+	return []ICEServer{{
+		URLs:       turnURLs,
+		Username:   username,
+		Credential: password,
+	}}
+}
+
+// EmbeddedTURNConfig 内置 TURN/STUN 服务器的配置
+type EmbeddedTURNConfig struct {
+	ListenAddr string // 监听地址，UDP 和 TCP 共用，例如 ":3478"
+	PublicIP   net.IP // 分配中继地址时返回给客户端的公网 IP
+	Realm      string
+	MinPort    uint16 // 中继端口范围下限
+	MaxPort    uint16 // 中继端口范围上限（含）
+}
+
+// startEmbeddedTURN 启动内置 TURN/STUN 服务器，使用与 turnCredentials 相同的密钥校验临时凭证
+func startEmbeddedTURN(cfg EmbeddedTURNConfig) (*turn.Server, error) {
+	if turnSecret == nil {
+		return nil, fmt.Errorf("embedded TURN requires a TURN secret")
+	}
+	if cfg.PublicIP == nil {
+		return nil, fmt.Errorf("embedded TURN requires a public IP")
+	}
+	if cfg.MinPort == 0 || cfg.MaxPort < cfg.MinPort {
+		return nil, fmt.Errorf("invalid relay port range %d-%d", cfg.MinPort, cfg.MaxPort)
+	}
+
+	udpConn, err := net.ListenPacket("udp4", cfg.ListenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("listen UDP %s: %w", cfg.ListenAddr, err)
+	}
+	tcpListener, err := net.Listen("tcp4", cfg.ListenAddr)
+	if err != nil {
+		udpConn.Close()
+		return nil, fmt.Errorf("listen TCP %s: %w", cfg.ListenAddr, err)
+	}
+
+	newRelayGenerator := func() turn.RelayAddressGenerator {
+		return &turn.RelayAddressGeneratorPortRange{
+			RelayAddress: cfg.PublicIP,
+			Address:      "0.0.0.0",
+			MinPort:      cfg.MinPort,
+			MaxPort:      cfg.MaxPort,
+		}
+	}
+	server, err := turn.NewServer(turn.ServerConfig{
+		Realm:       cfg.Realm,
+		AuthHandler: turnAuthHandler,
+		PacketConnConfigs: []turn.PacketConnConfig{{
+			PacketConn:            udpConn,
+			RelayAddressGenerator: newRelayGenerator(),
+		}},
+		ListenerConfigs: []turn.ListenerConfig{{
+			Listener:              tcpListener,
+			RelayAddressGenerator: newRelayGenerator(),
+		}},
+	})
+	if err != nil {
+		udpConn.Close()
+		tcpListener.Close()
+		return nil, err
+	}
+	return server, nil
+}
+
+// embeddedTURNURLs 返回内置 TURN/STUN 服务器对外公布的地址
+func embeddedTURNURLs(cfg EmbeddedTURNConfig) ([]string, error) {
+	_, port, err := net.SplitHostPort(cfg.ListenAddr)
+	if err != nil {
+		return nil, err
+	}
+	host := net.JoinHostPort(cfg.PublicIP.String(), port)
+	return []string{
+		"stun:" + host,
+		"turn:" + host + "?transport=udp",
+		"turn:" + host + "?transport=tcp",
+	}, nil
+}
+
+// turnAuthHandler 校验 "过期时间戳:用户标识" 格式的临时凭证，返回对应的长期凭证密钥
+func turnAuthHandler(username, realm string, srcAddr net.Addr) ([]byte, bool) {
+	expiry, _, _ := strings.Cut(username, ":")
+	t, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil || time.Now().Unix() >= t {
+		log.Printf("TURN auth rejected for %q from %s\n", username, srcAddr)
+		return nil, false
+	}
+	return turn.GenerateAuthKey(username, realm, turnPassword(turnSecret, username)), true
+}
@@ -0,0 +1,214 @@
+// approval.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Viewer 连接的确认方式
+const (
+	approvalAuto   = "auto"   // 直接接受
+	approvalPrompt = "prompt" // 在控制台或本地确认网页中确认，白名单中的 Viewer 除外
+)
+
+// 拒绝连接的原因，作为 session_rejected 的 reason 发送给 Viewer
+const (
+	rejectDeclined = "declined" // 桌面端操作者拒绝
+	rejectTimeout  = "timeout"  // 超时未确认
+
+	rejectDisconnected = "disconnected" // 等待确认期间 Viewer 断开，不需要通知
+)
+
+// PendingViewer 等待本地确认的 Viewer
+type PendingViewer struct {
+	ViewerID string
+	Subject  string // 令牌持有者，信令服务器未启用令牌校验时为空
+	ViewOnly bool
+	Since    time.Time
+
+	offer      Message           // 最近收到的 Offer，确认后据此应答
+	candidates []json.RawMessage // 等待期间收到的 ICE 候选，应答后添加
+	decision   chan string       // 确认结果：空字符串为接受，否则为拒绝原因
+}
+
+// approvalQueue 管理等待确认和已确认的 Viewer
+type approvalQueue struct {
+	mutex    sync.Mutex
+	pending  map[string]*PendingViewer // 以 viewer ID 为键
+	approved map[string]bool           // 已确认的 Viewer，断开后移除
+}
+
+// approvals 全局确认队列
+var approvals = &approvalQueue{
+	pending:  make(map[string]*PendingViewer),
+	approved: make(map[string]bool),
+}
+
+// admit 判断是否可以直接应答 Viewer 的 Offer：已确认、确认方式为 auto 或在白名单中时返回 true；
+// 否则登记为待确认并在确认后重新处理 Offer，已在等待时只更新其 Offer
+func (q *approvalQueue) admit(msg Message, client *Client) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	if q.approved[msg.ViewerID] {
+		return true
+	}
+	if config.Approval.Mode == approvalAuto || allowlisted(msg.Subject) {
+		q.approved[msg.ViewerID] = true
+		return true
+	}
+	if pending, ok := q.pending[msg.ViewerID]; ok {
+		pending.offer = msg
+		return false
+	}
+
+	pending := &PendingViewer{
+		ViewerID: msg.ViewerID,
+		Subject:  msg.Subject,
+		ViewOnly: msg.ViewOnly,
+		Since:    time.Now(),
+		offer:    msg,
+		decision: make(chan string, 1),
+	}
+	q.pending[msg.ViewerID] = pending
+	log.Printf("Viewer %s wants to connect, type \"approve %s\" or \"reject %s\" to answer.",
+		describeViewer(msg.ViewerID, msg.Subject), msg.ViewerID, msg.ViewerID)
+	go q.await(pending, client)
+	return false
+}
+
+// await 等待操作者确认或超时，接受后应答 Viewer 最近的 Offer 并添加期间收到的 ICE 候选，
+// 拒绝时通过信令通知 Viewer
+func (q *approvalQueue) await(pending *PendingViewer, client *Client) {
+	timer := time.NewTimer(time.Duration(config.Approval.Timeout))
+	defer timer.Stop()
+	var reason string
+	select {
+	case reason = <-pending.decision:
+	case <-timer.C:
+		reason = rejectTimeout
+	}
+
+	q.mutex.Lock()
+	if reason == "" {
+		q.approved[pending.ViewerID] = true
+	} else if q.pending[pending.ViewerID] == pending {
+		delete(q.pending, pending.ViewerID)
+	}
+	offer := pending.offer
+	q.mutex.Unlock()
+
+	switch reason {
+	case "":
+		log.Printf("Approved viewer %s.", describeViewer(pending.ViewerID, pending.Subject))
+		handleOffer(offer, client)
+		// 应答之前收到的候选仍由等待记录暂存，PeerConnection 创建后一并添加
+		q.mutex.Lock()
+		if q.pending[pending.ViewerID] == pending {
+			delete(q.pending, pending.ViewerID)
+		}
+		candidates := pending.candidates
+		q.mutex.Unlock()
+		for _, candidate := range candidates {
+			handleCandidate(pending.ViewerID, candidate)
+		}
+	case rejectDisconnected:
+	default:
+		log.Printf("Rejected viewer %s: %s.", describeViewer(pending.ViewerID, pending.Subject), reason)
+		rejectViewer(client, pending.ViewerID, reason)
+	}
+}
+
+// holdCandidate 暂存等待确认的 Viewer 发来的 ICE 候选，Viewer 不在等待时返回 false
+func (q *approvalQueue) holdCandidate(viewerID string, payload json.RawMessage) bool {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	pending, ok := q.pending[viewerID]
+	if ok {
+		pending.candidates = append(pending.candidates, payload)
+	}
+	return ok
+}
+
+// decide 接受或拒绝等待确认的 Viewer
+func (q *approvalQueue) decide(viewerID string, approve bool) error {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	pending, ok := q.pending[viewerID]
+	if !ok || len(pending.decision) > 0 || q.approved[viewerID] {
+		return fmt.Errorf("no pending connection from viewer %s", viewerID)
+	}
+	if approve {
+		pending.decision <- ""
+	} else {
+		pending.decision <- rejectDeclined
+	}
+	return nil
+}
+
+// forget 在 Viewer 断开后移除其确认状态
+func (q *approvalQueue) forget(viewerID string) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	delete(q.approved, viewerID)
+	if pending, ok := q.pending[viewerID]; ok {
+		delete(q.pending, viewerID)
+		if len(pending.decision) == 0 {
+			pending.decision <- rejectDisconnected
+		}
+	}
+}
+
+// list 返回等待确认的 Viewer，按请求时间排列
+func (q *approvalQueue) list() []PendingViewer {
+	q.mutex.Lock()
+	list := make([]PendingViewer, 0, len(q.pending))
+	for _, pending := range q.pending {
+		if len(pending.decision) == 0 && !q.approved[pending.ViewerID] {
+			list = append(list, *pending)
+		}
+	}
+	q.mutex.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Since.Before(list[j].Since) })
+	return list
+}
+
+// allowlisted 判断令牌持有者是否在免确认白名单中，不区分大小写
+func allowlisted(subject string) bool {
+	if subject == "" {
+		return false
+	}
+	for _, allowed := range config.Approval.Allowlist {
+		if strings.EqualFold(allowed, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// describeViewer 返回便于记录日志的 Viewer 描述
+func describeViewer(viewerID, subject string) string {
+	if subject == "" {
+		return viewerID
+	}
+	return fmt.Sprintf("%s (%s)", viewerID, subject)
+}
+
+// rejectViewer 通过信令服务器通知 Viewer 连接被拒绝
+func rejectViewer(client *Client, viewerID, reason string) {
+	payload, err := json.Marshal(map[string]string{"reason": reason})
+	if err != nil {
+		log.Println("Marshal session_rejected payload failed:", err)
+		return
+	}
+	sendMessage(client, Message{
+		Type:     "session_rejected",
+		ViewerID: viewerID,
+		Payload:  json.RawMessage(payload),
+	})
+}
@@ -0,0 +1,120 @@
+// approval_page.go
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"html/template"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// approvalPage 本地确认网页的模板，每 2 秒刷新一次等待确认的 Viewer
+var approvalPage = template.Must(template.New("approval").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="2">
+<title>远程桌面连接确认</title>
+</head>
+<body>
+<h1>远程桌面连接确认</h1>
+{{range .Pending}}
+<form method="post">
+  <input type="hidden" name="token" value="{{$.Token}}">
+  <input type="hidden" name="viewer" value="{{.ViewerID}}">
+  <p>Viewer {{.ViewerID}}{{if .Subject}}（{{.Subject}}）{{end}}{{if .ViewOnly}}，仅观看{{end}}，已等待 {{.Waiting}}</p>
+  <button formaction="/approve">允许</button>
+  <button formaction="/reject">拒绝</button>
+</form>
+{{else}}
+<p>没有等待确认的连接。</p>
+{{end}}
+</body>
+</html>
+`))
+
+// startApprovalPage 在本机地址上提供确认网页，页面中的随机令牌防止其它网站跨站提交确认，
+// Host 检查防止 DNS 重绑定的网页读取令牌，禁止嵌入框架防止其它网站诱导点击
+func startApprovalPage(addr string) error {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	token := hex.EncodeToString(b)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		type pendingItem struct {
+			PendingViewer
+			Waiting time.Duration
+		}
+		var items []pendingItem
+		for _, pending := range approvals.list() {
+			items = append(items, pendingItem{pending, time.Since(pending.Since).Round(time.Second)})
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := approvalPage.Execute(w, map[string]any{"Token": token, "Pending": items})
+		if err != nil {
+			log.Println("Render approval page failed:", err)
+		}
+	})
+	decide := func(approve bool) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			if subtle.ConstantTimeCompare([]byte(r.PostFormValue("token")), []byte(token)) != 1 {
+				http.Error(w, "invalid token", http.StatusForbidden)
+				return
+			}
+			if err := approvals.decide(r.PostFormValue("viewer"), approve); err != nil {
+				http.Error(w, err.Error(), http.StatusNotFound)
+				return
+			}
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+		}
+	}
+	mux.HandleFunc("/approve", decide(true))
+	mux.HandleFunc("/reject", decide(false))
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	log.Printf("Approval page available at http://%s/", listener.Addr())
+	server := &http.Server{Handler: approvalGuard(listener.Addr(), mux), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			log.Println("Approval page server failed:", err)
+		}
+	}()
+	return nil
+}
+
+// approvalGuard 只处理 Host 为监听地址（或同端口的 localhost）的请求，并禁止页面被嵌入框架
+func approvalGuard(listenAddr net.Addr, next http.Handler) http.Handler {
+	host, port, _ := net.SplitHostPort(listenAddr.String())
+	allowed := map[string]bool{
+		net.JoinHostPort(host, port):        true,
+		net.JoinHostPort("localhost", port): true,
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+		if !allowed[strings.ToLower(r.Host)] {
+			http.Error(w, "invalid host", http.StatusMisdirectedRequest)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,169 @@
+// bitrate.go
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/pion/interceptor"
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/interceptor/pkg/gcc"
+	"github.com/pion/webrtc/v3"
+)
+
+const (
+	bitrateCheckInterval = 2 * time.Second  // 检查带宽估计的间隔
+	bitrateRaiseDelay    = 10 * time.Second // 两次提升码率之间的最短间隔，避免频繁重启编码器
+	bitrateHysteresis    = 0.15             // 估计值与当前码率相差超过该比例才调整
+)
+
+// qualityLevel 码率阶梯中的一级：目标码率不低于 minBitrate（kbps）时使用的分辨率缩放和最高帧率
+type qualityLevel struct {
+	minBitrate   int
+	scale        float64
+	maxFramerate int
+}
+
+// qualityLadder 按码率从高到低排列，带宽不足时优先降低分辨率，再降低帧率
+var qualityLadder = []qualityLevel{
+	{minBitrate: 1500, scale: 1, maxFramerate: 60},
+	{minBitrate: 800, scale: 0.75, maxFramerate: 30},
+	{minBitrate: 400, scale: 0.5, maxFramerate: 20},
+	{minBitrate: 0, scale: 0.5, maxFramerate: 10},
+}
+
+var (
+	webrtcAPI       *webrtc.API                           // 带拥塞控制拦截器的 WebRTC API，启动时创建
+	estimatorChan   = make(chan cc.BandwidthEstimator, 1) // 新建 PeerConnection 时由拦截器传出的带宽估计器
+	peerCreateMutex sync.Mutex                            // 串行化 PeerConnection 的创建，保证估计器与连接一一对应
+)
+
+// newWebRTCAPI 创建启用 transport-wide congestion control 的 WebRTC API，
+// 每个 PeerConnection 使用独立的 GCC 带宽估计器。
+func newWebRTCAPI() (*webrtc.API, error) {
+	mediaEngine := &webrtc.MediaEngine{}
+	err := mediaEngine.RegisterDefaultCodecs()
+	if err != nil {
+		return nil, fmt.Errorf("register codecs: %w", err)
+	}
+
+	registry := &interceptor.Registry{}
+	congestionController, err := cc.NewInterceptor(func() (cc.BandwidthEstimator, error) {
+		return gcc.NewSendSideBWE(
+			gcc.SendSideBWEInitialBitrate(config.Encoder.Bitrate*1000),
+			gcc.SendSideBWEMinBitrate(config.Encoder.MinBitrate*1000),
+			gcc.SendSideBWEMaxBitrate(config.Encoder.MaxBitrate*1000),
+			// 码率由编码器控制，RTP 包不再经过 pacer 排队，避免关键帧被延迟发送
+			gcc.SendSideBWEPacer(gcc.NewNoOpPacer()),
+		)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("create congestion controller: %w", err)
+	}
+	congestionController.OnNewPeerConnection(func(id string, estimator cc.BandwidthEstimator) {
+		estimatorChan <- estimator
+	})
+	registry.Add(congestionController)
+
+	err = webrtc.ConfigureTWCCHeaderExtensionSender(mediaEngine, registry)
+	if err != nil {
+		return nil, fmt.Errorf("configure TWCC: %w", err)
+	}
+	err = webrtc.RegisterDefaultInterceptors(mediaEngine, registry)
+	if err != nil {
+		return nil, fmt.Errorf("register interceptors: %w", err)
+	}
+	return webrtc.NewAPI(webrtc.WithMediaEngine(mediaEngine), webrtc.WithInterceptorRegistry(registry)), nil
+}
+
+// newPeerConnection 创建 PeerConnection 并返回其带宽估计器
+func newPeerConnection(configuration webrtc.Configuration) (*webrtc.PeerConnection, cc.BandwidthEstimator, error) {
+	peerCreateMutex.Lock()
+	defer peerCreateMutex.Unlock()
+	peerConnection, err := webrtcAPI.NewPeerConnection(configuration)
+	if err != nil {
+		return nil, nil, err
+	}
+	select {
+	case estimator := <-estimatorChan:
+		return peerConnection, estimator, nil
+	default:
+		return peerConnection, nil, nil
+	}
+}
+
+// initialEncoderSettings 返回启动时的编码参数
+func initialEncoderSettings() EncoderSettings {
+	return encoderSettingsFor(config.Encoder.Bitrate)
+}
+
+// encoderSettingsFor 根据目标码率在码率阶梯中选择分辨率和帧率
+func encoderSettingsFor(bitrate int) EncoderSettings {
+	level := qualityLadder[len(qualityLadder)-1]
+	for _, l := range qualityLadder {
+		if bitrate >= l.minBitrate {
+			level = l
+			break
+		}
+	}
+	return EncoderSettings{
+		Bitrate:   bitrate,
+		Scale:     level.scale,
+		Framerate: min(config.Capture.Framerate, level.maxFramerate),
+	}
+}
+
+// targetBitrate 返回使用指定编码器的已连接 Viewer 的带宽估计中的最小值（kbps）。
+// 这些 Viewer 共享同一路编码，因此以最差的链路为准；没有可用估计时返回 false。
+func targetBitrate(encoder *Encoder) (int, bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	target, ok := 0, false
+	for _, peer := range peers {
+		if peer.encoder != encoder || peer.estimator == nil || peer.peerConnection.ICEConnectionState() != webrtc.ICEConnectionStateConnected {
+			continue
+		}
+		bitrate := peer.estimator.GetTargetBitrate() / 1000
+		if !ok || bitrate < target {
+			target, ok = bitrate, true
+		}
+	}
+	return target, ok
+}
+
+// runBitrateController 定期根据 RTCP 反馈得到的带宽估计调整各编码器的码率、分辨率和帧率，直到 done 关闭。
+// 降低码率立即生效，提升码率需间隔 bitrateRaiseDelay，以减少编码器重启。
+func runBitrateController(done <-chan struct{}) {
+	ticker := time.NewTicker(bitrateCheckInterval)
+	defer ticker.Stop()
+	lastRaise := make(map[*Encoder]time.Time)
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		for _, encoder := range encoders.all() {
+			target, ok := targetBitrate(encoder)
+			if !ok {
+				continue
+			}
+			target = max(config.Encoder.MinBitrate, min(config.Encoder.MaxBitrate, target))
+			current := encoder.Settings().Bitrate
+			switch {
+			case float64(target) < float64(current)*(1-bitrateHysteresis):
+				encoder.Reconfigure(encoderSettingsFor(target))
+			case float64(target) > float64(current)*(1+bitrateHysteresis) && time.Since(lastRaise[encoder]) >= bitrateRaiseDelay:
+				lastRaise[encoder] = time.Now()
+				encoder.Reconfigure(encoderSettingsFor(target))
+			default:
+				continue
+			}
+			log.Printf("Estimated bandwidth %d kbps, %s encoder target now %d kbps.",
+				target, encoder.codec.name, encoder.Settings().Bitrate)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// capture.go
+package main
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/kbinani/screenshot"
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// 屏幕采集后端
+const (
+	captureGDIGrab    = "gdigrab"    // Windows GDI 采集，由 FFmpeg 完成
+	captureX11Grab    = "x11grab"    // Linux X11 采集，由 FFmpeg 完成
+	captureScreenshot = "screenshot" // 使用 kbinani/screenshot 在 Go 中截屏，原始帧通过管道交给 FFmpeg
+	captureTestSrc    = "testsrc"    // FFmpeg 生成的测试图案，不需要显示器，适用于无头服务器和 CI
+)
+
+// Capturer 屏幕采集后端，为编码器提供 FFmpeg 输入
+type Capturer interface {
+	// Displays 返回可采集的显示器
+	Displays() ([]Display, error)
+	// Input 返回以指定帧率采集指定内容的 FFmpeg 输入
+	Input(framerate int, area captureArea) (*ffmpeg.Stream, error)
+}
+
+// frameWriter 由 Go 代码截取画面的采集后端实现该接口，
+// 编码器将 FFmpeg 的标准输入交给它写入原始帧，直到写入失败
+type frameWriter interface {
+	writeFrames(w io.Writer, framerate int, area image.Rectangle) error
+}
+
+// defaultCaptureBackend 返回当前平台默认的采集后端
+func defaultCaptureBackend() string {
+	switch runtime.GOOS {
+	case "windows":
+		return captureGDIGrab
+	case "linux":
+		return captureX11Grab
+	default:
+		return captureScreenshot
+	}
+}
+
+// newCapturer 根据配置创建采集后端，input 为空时使用各后端的默认输入
+func newCapturer(cfg CaptureConfig) (Capturer, error) {
+	switch cfg.Backend {
+	case captureGDIGrab:
+		return &gdigrabCapturer{input: valueOr(cfg.Input, "desktop")}, nil
+	case captureX11Grab:
+		return &x11grabCapturer{display: valueOr(cfg.Input, valueOr(os.Getenv("DISPLAY"), ":0.0"))}, nil
+	case captureScreenshot:
+		return &screenshotCapturer{}, nil
+	case captureTestSrc:
+		size := valueOr(cfg.Input, "1280x720")
+		var width, height int
+		if _, err := fmt.Sscanf(size, "%dx%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+			return nil, fmt.Errorf("testsrc capture input %q must be a size such as 1280x720", cfg.Input)
+		}
+		return &testsrcCapturer{width: width, height: height}, nil
+	default:
+		return nil, fmt.Errorf("unknown capture backend %q", cfg.Backend)
+	}
+}
+
+// gdigrabCapturer 使用 FFmpeg gdigrab 采集 Windows 桌面
+type gdigrabCapturer struct {
+	input string // 例如 "desktop" 或 "title=窗口标题"
+}
+
+func (c *gdigrabCapturer) Displays() ([]Display, error) {
+	return screenDisplays()
+}
+
+// Input 采集窗口时使用 title= 直接采集该窗口，窗口移动后仍能跟随；
+// 采集桌面时只截取指定区域；配置中指定了其它输入时忽略区域
+func (c *gdigrabCapturer) Input(framerate int, area captureArea) (*ffmpeg.Stream, error) {
+	args := ffmpeg.KwArgs{
+		"f":                           "gdigrab",
+		"framerate":                   strconv.Itoa(framerate),
+		"use_wallclock_as_timestamps": "1", // 以采集时的墙上时钟作为 PTS，见 ivfCaptureTime
+	}
+	if area.window != "" {
+		return ffmpeg.Input("title="+area.window, args), nil
+	}
+	if c.input == "desktop" {
+		// gdigrab 的偏移量为虚拟桌面坐标，副显示器位于主显示器左侧或上方时可为负数
+		args["offset_x"] = strconv.Itoa(area.bounds.Min.X)
+		args["offset_y"] = strconv.Itoa(area.bounds.Min.Y)
+		args["video_size"] = fmt.Sprintf("%dx%d", area.bounds.Dx(), area.bounds.Dy())
+	}
+	return ffmpeg.Input(c.input, args), nil
+}
+
+// x11grabCapturer 使用 FFmpeg x11grab 采集 Linux X11 桌面
+type x11grabCapturer struct {
+	display string // X11 显示，例如 ":0.0"
+}
+
+func (c *x11grabCapturer) Displays() ([]Display, error) {
+	return screenDisplays()
+}
+
+// Input 采集窗口时按窗口当前所在的区域采集
+func (c *x11grabCapturer) Input(framerate int, area captureArea) (*ffmpeg.Stream, error) {
+	bounds := area.bounds
+	return ffmpeg.Input(fmt.Sprintf("%s+%d,%d", c.display, bounds.Min.X, bounds.Min.Y), ffmpeg.KwArgs{
+		"f":                           "x11grab",
+		"framerate":                   strconv.Itoa(framerate),
+		"video_size":                  fmt.Sprintf("%dx%d", bounds.Dx(), bounds.Dy()),
+		"use_wallclock_as_timestamps": "1", // 以采集时的墙上时钟作为 PTS，见 ivfCaptureTime
+	}), nil
+}
+
+// testsrcCapturer 使用 FFmpeg lavfi testsrc2 生成带时间码的测试图案，视为一个位于原点的显示器
+type testsrcCapturer struct {
+	width  int
+	height int
+}
+
+func (c *testsrcCapturer) Displays() ([]Display, error) {
+	return []Display{{Index: 0, Width: c.width, Height: c.height, Primary: true}}, nil
+}
+
+func (c *testsrcCapturer) Input(framerate int, area captureArea) (*ffmpeg.Stream, error) {
+	size := area.bounds.Size()
+	return ffmpeg.Input(fmt.Sprintf("testsrc2=size=%dx%d:rate=%d", size.X, size.Y, framerate), ffmpeg.KwArgs{
+		"f":                           "lavfi",
+		"re":                          "",  // 按实际帧率输出，而不是尽可能快地生成
+		"use_wallclock_as_timestamps": "1", // 以采集时的墙上时钟作为 PTS，见 ivfCaptureTime
+	}), nil
+}
+
+// screenshotCapturer 使用 kbinani/screenshot 截取指定区域，以 RGBA 原始帧输入 FFmpeg
+type screenshotCapturer struct{}
+
+func (c *screenshotCapturer) Displays() ([]Display, error) {
+	return screenDisplays()
+}
+
+func (c *screenshotCapturer) Input(framerate int, area captureArea) (*ffmpeg.Stream, error) {
+	return ffmpeg.Input("pipe:0", ffmpeg.KwArgs{
+		"f":                           "rawvideo",
+		"pix_fmt":                     "rgba",
+		"video_size":                  fmt.Sprintf("%dx%d", area.bounds.Dx(), area.bounds.Dy()),
+		"framerate":                   strconv.Itoa(framerate),
+		"use_wallclock_as_timestamps": "1", // 以采集时的墙上时钟作为 PTS，见 ivfCaptureTime
+	}), nil
+}
+
+// writeFrames 按帧率截取指定区域并写入原始帧
+func (c *screenshotCapturer) writeFrames(w io.Writer, framerate int, area image.Rectangle) error {
+	ticker := time.NewTicker(time.Second / time.Duration(framerate))
+	defer ticker.Stop()
+	for range ticker.C {
+		img, err := captureScreen(area)
+		if err != nil {
+			return fmt.Errorf("capture screen: %w", err)
+		}
+		if _, err := w.Write(img.Pix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// captureScreen 截取屏幕的指定区域
+func captureScreen(bounds image.Rectangle) (*image.RGBA, error) {
+	return screenshot.CaptureRect(bounds)
+}
+
+// valueOr 返回 value，为空时返回 fallback
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,307 @@
+// clipboard.go
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-vgo/robotgo"
+	"github.com/pion/webrtc/v3"
+)
+
+// 剪贴板内容格式
+const (
+	clipboardText = "text"      // UTF-8 文本
+	clipboardPNG  = "image/png" // PNG 图片
+)
+
+// 剪贴板同步方向
+const (
+	clipboardOff        = "off"         // 不同步
+	clipboardBoth       = "both"        // 双向同步
+	clipboardToViewer   = "to_viewer"   // 只把桌面端的剪贴板发给 Viewer
+	clipboardFromViewer = "from_viewer" // 只接收 Viewer 的剪贴板
+)
+
+const (
+	clipboardPollInterval = 500 * time.Millisecond // 检查桌面端剪贴板变化的间隔
+	clipboardChunkSize    = 16 * 1024              // 剪贴板内容分块发送的大小，低于 SCTP 的消息大小上限
+	// clipboardMaxImagePixels 解码剪贴板图片的最大像素数（8K 分辨率）。PNG 压缩率可以极高，
+	// MaxSize 只限制压缩后的大小，解码前需按图片声明的尺寸检查，避免很小的 PNG 引起巨大的内存分配
+	clipboardMaxImagePixels = 7680 * 4320
+)
+
+// errImageClipboardUnsupported 表示当前平台不支持读写剪贴板中的图片
+var errImageClipboardUnsupported = errors.New("image clipboard is not supported on this platform")
+
+// ClipboardChunk 通过 DataChannel 发送给 Viewer 的剪贴板内容，较大的内容分多块依次发送
+type ClipboardChunk struct {
+	Type   string `json:"type"`   // "clipboard"
+	Format string `json:"format"` // "text" 或 "image/png"
+	Data   string `json:"data"`   // base64 编码的内容片段
+	Final  bool   `json:"final"`  // 最后一块，Viewer 收到后写入剪贴板
+}
+
+// ClipboardPolicy 通过 DataChannel 发送给 Viewer 的剪贴板同步策略
+type ClipboardPolicy struct {
+	Type    string `json:"type"`     // "clipboard_policy"
+	Mode    string `json:"mode"`     // 同步方向，见 ClipboardConfig
+	Images  bool   `json:"images"`   // 是否同步 PNG 图片
+	MaxSize int    `json:"max_size"` // 单次同步内容的最大字节数
+}
+
+// clipboardContent 剪贴板中的一份内容
+type clipboardContent struct {
+	format string
+	data   []byte
+}
+
+// clipboardState 记录最近一次同步的剪贴板内容，避免把刚写入的内容再发回去
+var clipboardState struct {
+	mutex sync.Mutex
+	last  clipboardContent
+}
+
+// sendsToViewer 和 receivesFromViewer 判断配置的同步方向
+func sendsToViewer() bool {
+	return config.Clipboard.Mode == clipboardBoth || config.Clipboard.Mode == clipboardToViewer
+}
+
+func receivesFromViewer() bool {
+	return config.Clipboard.Mode == clipboardBoth || config.Clipboard.Mode == clipboardFromViewer
+}
+
+// runClipboardWatcher 定期检查桌面端剪贴板，内容变化时发给开启同步且有控制权限的 Viewer。
+// 没有这样的 Viewer 时不读取剪贴板；平台能报告剪贴板变化时（见 clipboardSequence），未变化时也不读取。
+// 开始同步时剪贴板中已有的内容只作为比较的基准，不发送
+func runClipboardWatcher(done <-chan struct{}) {
+	if !sendsToViewer() {
+		return
+	}
+	images := config.Clipboard.Images
+	ticker := time.NewTicker(clipboardPollInterval)
+	defer ticker.Stop()
+	watching := false       // 上一次检查时是否有需要同步的 Viewer
+	var lastSequence uint64 // 上一次读取剪贴板时的变化序号
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		if !clipboardWanted() {
+			watching = false
+			continue
+		}
+		sequence, tracked := clipboardSequence()
+		if watching && tracked && sequence == lastSequence {
+			continue
+		}
+		content, err := readClipboard(images)
+		if errors.Is(err, errImageClipboardUnsupported) {
+			log.Println("Image clipboard sync disabled:", err)
+			images = false
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		lastSequence = sequence
+
+		clipboardState.mutex.Lock()
+		changed := content.format != clipboardState.last.format || !bytes.Equal(content.data, clipboardState.last.data)
+		clipboardState.last = content
+		clipboardState.mutex.Unlock()
+		if !watching {
+			watching = true
+			continue
+		}
+		if !changed || content.data == nil {
+			continue
+		}
+		if len(content.data) > config.Clipboard.MaxSize {
+			log.Printf("Clipboard %s of %d bytes exceeds the %d byte limit, not sent.",
+				content.format, len(content.data), config.Clipboard.MaxSize)
+			continue
+		}
+		broadcastClipboard(content)
+	}
+}
+
+// clipboardWanted 判断是否有开启同步且有控制权限的 Viewer
+func clipboardWanted() bool {
+	mutex.Lock()
+	list := make([]*ViewerPeer, 0, len(peers))
+	for _, peer := range peers {
+		list = append(list, peer)
+	}
+	mutex.Unlock()
+
+	for _, peer := range list {
+		peer.controlMutex.Lock()
+		enabled := peer.controlGranted && peer.clipboardSync
+		peer.controlMutex.Unlock()
+		if enabled {
+			return true
+		}
+	}
+	return false
+}
+
+// readClipboard 读取桌面端的剪贴板，开启图片同步且剪贴板中有图片时优先返回图片
+func readClipboard(images bool) (clipboardContent, error) {
+	if images {
+		data, err := readClipboardImage()
+		if err != nil {
+			return clipboardContent{}, err
+		}
+		if data != nil {
+			return clipboardContent{format: clipboardPNG, data: data}, nil
+		}
+	}
+	text, err := robotgo.ReadAll()
+	if err != nil || text == "" {
+		return clipboardContent{}, err
+	}
+	return clipboardContent{format: clipboardText, data: []byte(text)}, nil
+}
+
+// broadcastClipboard 将剪贴板内容发给开启同步且有控制权限的 Viewer
+func broadcastClipboard(content clipboardContent) {
+	mutex.Lock()
+	list := make([]*ViewerPeer, 0, len(peers))
+	for _, peer := range peers {
+		list = append(list, peer)
+	}
+	mutex.Unlock()
+
+	for _, peer := range list {
+		peer.controlMutex.Lock()
+		enabled := peer.controlGranted && peer.clipboardSync
+		peer.controlMutex.Unlock()
+		if enabled {
+			sendClipboard(peer, content)
+		}
+	}
+}
+
+// sendClipboard 分块发送剪贴板内容
+func sendClipboard(peer *ViewerPeer, content clipboardContent) {
+	if peer.dataChannel.ReadyState() != webrtc.DataChannelStateOpen {
+		return
+	}
+	for offset := 0; offset == 0 || offset < len(content.data); offset += clipboardChunkSize {
+		end := min(offset+clipboardChunkSize, len(content.data))
+		data, err := json.Marshal(ClipboardChunk{
+			Type:   "clipboard",
+			Format: content.format,
+			Data:   base64.StdEncoding.EncodeToString(content.data[offset:end]),
+			Final:  end == len(content.data),
+		})
+		if err != nil {
+			log.Println("Marshal clipboard chunk failed:", err)
+			return
+		}
+		if err := peer.dataChannel.SendText(string(data)); err != nil {
+			log.Printf("Failed to send clipboard to viewer %s: %v", peer.viewerID, err)
+			return
+		}
+	}
+	log.Printf("Sent clipboard %s of %d bytes to viewer %s.", content.format, len(content.data), peer.viewerID)
+}
+
+// sendClipboardPolicy 通过 DataChannel 向 Viewer 发送剪贴板同步策略
+func sendClipboardPolicy(peer *ViewerPeer) {
+	if peer.dataChannel.ReadyState() != webrtc.DataChannelStateOpen {
+		return
+	}
+	data, err := json.Marshal(ClipboardPolicy{
+		Type:    "clipboard_policy",
+		Mode:    config.Clipboard.Mode,
+		Images:  config.Clipboard.Images,
+		MaxSize: config.Clipboard.MaxSize,
+	})
+	if err != nil {
+		log.Println("Marshal clipboard policy failed:", err)
+		return
+	}
+	err = peer.dataChannel.SendText(string(data))
+	if err != nil {
+		log.Printf("Failed to send clipboard policy to viewer %s: %v", peer.viewerID, err)
+	}
+}
+
+// receiveClipboard 拼接 Viewer 发来的剪贴板分块，收到最后一块后写入桌面端剪贴板。
+// 只在 DataChannel 的消息回调中调用，分块缓冲区不需要加锁。
+func (p *ViewerPeer) receiveClipboard(chunk *ClipboardData) {
+	if !receivesFromViewer() {
+		log.Printf("Dropped clipboard from viewer %s, clipboard sync from viewers is disabled.", p.viewerID)
+		return
+	}
+	p.controlMutex.Lock()
+	enabled := p.clipboardSync
+	p.controlMutex.Unlock()
+	if !enabled {
+		return
+	}
+	if chunk.Format == clipboardPNG && !config.Clipboard.Images {
+		log.Printf("Dropped clipboard image from viewer %s, image sync is disabled.", p.viewerID)
+		return
+	}
+	if p.clipboardFormat != chunk.Format {
+		p.clipboardBuffer = p.clipboardBuffer[:0]
+		p.clipboardFormat = chunk.Format
+	}
+	if len(p.clipboardBuffer)+len(chunk.Data) > config.Clipboard.MaxSize {
+		// 丢弃超限的内容，直到下一次同步开始
+		if !p.clipboardOversize {
+			log.Printf("Clipboard from viewer %s exceeds the %d byte limit, dropped.", p.viewerID, config.Clipboard.MaxSize)
+		}
+		p.clipboardBuffer, p.clipboardOversize = p.clipboardBuffer[:0], !chunk.Final
+		return
+	}
+	if p.clipboardOversize {
+		p.clipboardOversize = !chunk.Final
+		return
+	}
+	p.clipboardBuffer = append(p.clipboardBuffer, chunk.Data...)
+	if !chunk.Final {
+		return
+	}
+
+	content := clipboardContent{format: chunk.Format, data: bytes.Clone(p.clipboardBuffer)}
+	p.clipboardBuffer = p.clipboardBuffer[:0]
+	if err := writeClipboard(content); err != nil {
+		log.Printf("Failed to apply clipboard from viewer %s: %v", p.viewerID, err)
+		return
+	}
+	log.Printf("Applied clipboard %s of %d bytes from viewer %s.", content.format, len(content.data), p.viewerID)
+}
+
+// writeClipboard 写入桌面端剪贴板，并记为最近同步的内容
+func writeClipboard(content clipboardContent) error {
+	var err error
+	switch content.format {
+	case clipboardText:
+		if !utf8.Valid(content.data) {
+			return errors.New("clipboard text is not valid UTF-8")
+		}
+		err = robotgo.WriteAll(string(content.data))
+	case clipboardPNG:
+		err = writeClipboardImage(content.data)
+	}
+	if err != nil {
+		return err
+	}
+	clipboardState.mutex.Lock()
+	clipboardState.last = content
+	clipboardState.mutex.Unlock()
+	return nil
+}
@@ -0,0 +1,116 @@
+// clipboard_linux.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xfixes"
+	"github.com/jezek/xgb/xproto"
+)
+
+// clipboardOwner 通过 XFixes 监视 CLIPBOARD 所有者的变化，每次复制时复制的程序都会重新成为所有者
+var clipboardOwner struct {
+	changes atomic.Uint64 // 所有者变化的次数
+	closed  atomic.Bool   // 与 X 服务器的连接已断开
+}
+
+// watchClipboardOwner 订阅 CLIPBOARD 所有者变化的事件，只在第一次调用时连接 X 服务器；
+// 无法监视时返回 false，由调用方每次读取剪贴板
+var watchClipboardOwner = sync.OnceValue(func() bool {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		log.Println("Clipboard change detection unavailable, polling the clipboard:", err)
+		return false
+	}
+	err = subscribeClipboardOwner(conn)
+	if err != nil {
+		conn.Close()
+		log.Println("Clipboard change detection unavailable, polling the clipboard:", err)
+		return false
+	}
+	go func() {
+		for {
+			event, xerr := conn.WaitForEvent()
+			if event == nil && xerr == nil {
+				clipboardOwner.closed.Store(true)
+				log.Println("X connection for clipboard change detection closed, polling the clipboard.")
+				return
+			}
+			if _, ok := event.(xfixes.SelectionNotifyEvent); ok {
+				clipboardOwner.changes.Add(1)
+			}
+		}
+	}()
+	return true
+})
+
+// subscribeClipboardOwner 通过 XFixes 在根窗口上接收 CLIPBOARD 所有者变化、所有者窗口销毁和客户端退出的事件
+func subscribeClipboardOwner(conn *xgb.Conn) error {
+	if err := xfixes.Init(conn); err != nil {
+		return fmt.Errorf("init XFixes: %w", err)
+	}
+	// 使用 XFixes 请求之前必须先协商版本，选择事件需要 2.0 或更新的版本
+	if _, err := xfixes.QueryVersion(conn, 5, 0).Reply(); err != nil {
+		return fmt.Errorf("query XFixes version: %w", err)
+	}
+	// 尚无程序使用过剪贴板时 CLIPBOARD 原子可能还不存在，需要创建
+	name := "CLIPBOARD"
+	atom, err := xproto.InternAtom(conn, false, uint16(len(name)), name).Reply()
+	if err != nil {
+		return fmt.Errorf("intern atom %s: %w", name, err)
+	}
+	root := xproto.Setup(conn).DefaultScreen(conn).Root
+	mask := uint32(xfixes.SelectionEventMaskSetSelectionOwner |
+		xfixes.SelectionEventMaskSelectionWindowDestroy |
+		xfixes.SelectionEventMaskSelectionClientClose)
+	if err := xfixes.SelectSelectionInputChecked(conn, root, atom.Atom, mask).Check(); err != nil {
+		return fmt.Errorf("select clipboard events: %w", err)
+	}
+	return nil
+}
+
+// clipboardSequence 返回 CLIPBOARD 所有者变化的次数，剪贴板内容变化时递增；无法监视时返回 false
+func clipboardSequence() (uint64, bool) {
+	if !watchClipboardOwner() || clipboardOwner.closed.Load() {
+		return 0, false
+	}
+	return clipboardOwner.changes.Load(), true
+}
+
+// readClipboardImage 通过 xclip 读取剪贴板中的 PNG 图片，剪贴板中没有图片时返回 nil
+func readClipboardImage() ([]byte, error) {
+	if _, err := exec.LookPath("xclip"); err != nil {
+		return nil, fmt.Errorf("%w: xclip not found", errImageClipboardUnsupported)
+	}
+	// 剪贴板为空时 xclip 以非零状态退出，视为没有图片
+	targets, err := exec.Command("xclip", "-selection", "clipboard", "-t", "TARGETS", "-o").Output()
+	if err != nil || !strings.Contains(string(targets), clipboardPNG) {
+		return nil, nil
+	}
+	data, err := exec.Command("xclip", "-selection", "clipboard", "-t", clipboardPNG, "-o").Output()
+	if err != nil {
+		return nil, fmt.Errorf("read clipboard image: %w", err)
+	}
+	return data, nil
+}
+
+// writeClipboardImage 通过 xclip 将 PNG 图片写入剪贴板。
+// xclip 会留在后台提供剪贴板内容，因此不读取其输出，避免等待后台进程关闭管道。
+func writeClipboardImage(data []byte) error {
+	if _, err := exec.LookPath("xclip"); err != nil {
+		return fmt.Errorf("%w: xclip not found", errImageClipboardUnsupported)
+	}
+	cmd := exec.Command("xclip", "-selection", "clipboard", "-t", clipboardPNG, "-i")
+	cmd.Stdin = bytes.NewReader(data)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("write clipboard image: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+//go:build !windows && !linux
+
+// clipboard_other.go
+package main
+
+// readClipboardImage 当前平台不支持读取剪贴板中的图片
+func readClipboardImage() ([]byte, error) {
+	return nil, errImageClipboardUnsupported
+}
+
+// writeClipboardImage 当前平台不支持写入剪贴板中的图片
+func writeClipboardImage(data []byte) error {
+	return errImageClipboardUnsupported
+}
+
+// clipboardSequence 当前平台无法得知剪贴板是否变化，每次检查都读取剪贴板
+func clipboardSequence() (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,293 @@
+// clipboard_windows.go
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"runtime"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+const (
+	cfDIB        = 8      // CF_DIB，BITMAPINFOHEADER 加像素数据
+	gmemMoveable = 0x0002 // GMEM_MOVEABLE，SetClipboardData 要求的内存类型
+	biRGB        = 0      // BI_RGB，未压缩
+	biBitfields  = 3      // BI_BITFIELDS，像素格式由颜色掩码给出
+)
+
+var (
+	procOpenClipboard              = user32.NewProc("OpenClipboard")
+	procCloseClipboard             = user32.NewProc("CloseClipboard")
+	procEmptyClipboard             = user32.NewProc("EmptyClipboard")
+	procGetClipboardData           = user32.NewProc("GetClipboardData")
+	procSetClipboardData           = user32.NewProc("SetClipboardData")
+	procIsClipboardFormatAvailable = user32.NewProc("IsClipboardFormatAvailable")
+	procRegisterClipboardFormatW   = user32.NewProc("RegisterClipboardFormatW")
+	procGetClipboardSequenceNumber = user32.NewProc("GetClipboardSequenceNumber")
+	kernel32                       = syscall.NewLazyDLL("kernel32.dll")
+	procGlobalAlloc                = kernel32.NewProc("GlobalAlloc")
+	procGlobalFree                 = kernel32.NewProc("GlobalFree")
+	procGlobalLock                 = kernel32.NewProc("GlobalLock")
+	procGlobalUnlock               = kernel32.NewProc("GlobalUnlock")
+	procGlobalSize                 = kernel32.NewProc("GlobalSize")
+	procRtlMoveMemory              = kernel32.NewProc("RtlMoveMemory")
+)
+
+// cfPNG 浏览器、Office 等程序使用的 "PNG" 剪贴板格式，注册失败时为 0
+var cfPNG = sync.OnceValue(func() uintptr {
+	name, _ := syscall.UTF16PtrFromString("PNG")
+	format, _, _ := procRegisterClipboardFormatW.Call(uintptr(unsafe.Pointer(name)))
+	return format
+})
+
+// bitmapInfoHeader BITMAPINFOHEADER
+type bitmapInfoHeader struct {
+	Size          uint32
+	Width         int32
+	Height        int32
+	Planes        uint16
+	BitCount      uint16
+	Compression   uint32
+	SizeImage     uint32
+	XPelsPerMeter int32
+	YPelsPerMeter int32
+	ClrUsed       uint32
+	ClrImportant  uint32
+}
+
+// imageCache 剪贴板未变化时复用上次读取的图片，避免每次检查都把位图重新编码为 PNG；只在剪贴板监视中访问
+var imageCache struct {
+	sequence uintptr
+	data     []byte
+}
+
+// clipboardSequence 返回剪贴板的变化序号，剪贴板内容每次变化时递增；无法获取时返回 false
+func clipboardSequence() (uint64, bool) {
+	sequence, _, _ := procGetClipboardSequenceNumber.Call()
+	return uint64(sequence), sequence != 0
+}
+
+// readClipboardImage 读取剪贴板中的图片并返回 PNG 数据，优先使用 "PNG" 格式，否则将 CF_DIB 位图编码为 PNG；
+// 剪贴板中没有图片时返回 nil
+func readClipboardImage() ([]byte, error) {
+	sequence, _, _ := procGetClipboardSequenceNumber.Call()
+	if sequence != 0 && sequence == imageCache.sequence {
+		return imageCache.data, nil
+	}
+
+	pngFormat := cfPNG()
+	hasPNG := pngFormat != 0 && formatAvailable(pngFormat)
+	if !hasPNG && !formatAvailable(cfDIB) {
+		imageCache.sequence, imageCache.data = sequence, nil
+		return nil, nil
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := openClipboard(); err != nil {
+		return nil, err
+	}
+	defer procCloseClipboard.Call()
+
+	var data []byte
+	if hasPNG {
+		var err error
+		if data, err = clipboardBytes(pngFormat); err != nil {
+			return nil, err
+		}
+	} else {
+		dib, err := clipboardBytes(cfDIB)
+		if err != nil {
+			return nil, err
+		}
+		img, err := decodeDIB(dib)
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, fmt.Errorf("encode PNG: %w", err)
+		}
+		data = buf.Bytes()
+	}
+	imageCache.sequence, imageCache.data = sequence, data
+	return data, nil
+}
+
+// writeClipboardImage 将 PNG 图片写入剪贴板，同时提供 "PNG" 和 CF_DIB 格式，只支持位图的程序也能粘贴
+func writeClipboardImage(data []byte) error {
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("decode PNG: %w", err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > clipboardMaxImagePixels {
+		return fmt.Errorf("PNG of %dx%d exceeds the %d pixel limit", cfg.Width, cfg.Height, clipboardMaxImagePixels)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("decode PNG: %w", err)
+	}
+	dib := encodeDIB(img)
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	if err := openClipboard(); err != nil {
+		return err
+	}
+	defer procCloseClipboard.Call()
+
+	if ret, _, err := procEmptyClipboard.Call(); ret == 0 {
+		return fmt.Errorf("empty clipboard: %w", err)
+	}
+	if format := cfPNG(); format != 0 {
+		if err := setClipboardBytes(format, data); err != nil {
+			return err
+		}
+	}
+	return setClipboardBytes(cfDIB, dib)
+}
+
+// openClipboard 打开剪贴板，其他程序占用时稍后重试；调用方需锁定线程并在完成后关闭剪贴板
+func openClipboard() error {
+	var err error
+	for i := 0; i < 10; i++ {
+		var ret uintptr
+		if ret, _, err = procOpenClipboard.Call(0); ret != 0 {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Errorf("open clipboard: %w", err)
+}
+
+// formatAvailable 判断剪贴板中是否有指定格式的内容，不需要打开剪贴板
+func formatAvailable(format uintptr) bool {
+	ret, _, _ := procIsClipboardFormatAvailable.Call(format)
+	return ret != 0
+}
+
+// clipboardBytes 复制剪贴板中指定格式的内容
+func clipboardBytes(format uintptr) ([]byte, error) {
+	handle, _, err := procGetClipboardData.Call(format)
+	if handle == 0 {
+		return nil, fmt.Errorf("get clipboard data: %w", err)
+	}
+	size, _, _ := procGlobalSize.Call(handle)
+	ptr, _, err := procGlobalLock.Call(handle)
+	if ptr == 0 {
+		return nil, fmt.Errorf("lock clipboard data: %w", err)
+	}
+	defer procGlobalUnlock.Call(handle)
+
+	data := make([]byte, size)
+	if size > 0 {
+		procRtlMoveMemory.Call(uintptr(unsafe.Pointer(&data[0])), ptr, size)
+	}
+	return data, nil
+}
+
+// setClipboardBytes 以指定格式写入剪贴板，写入成功后内存归剪贴板所有
+func setClipboardBytes(format uintptr, data []byte) error {
+	handle, _, err := procGlobalAlloc.Call(gmemMoveable, uintptr(len(data)))
+	if handle == 0 {
+		return fmt.Errorf("allocate clipboard data: %w", err)
+	}
+	ptr, _, err := procGlobalLock.Call(handle)
+	if ptr == 0 {
+		procGlobalFree.Call(handle)
+		return fmt.Errorf("lock clipboard data: %w", err)
+	}
+	if len(data) > 0 {
+		procRtlMoveMemory.Call(ptr, uintptr(unsafe.Pointer(&data[0])), uintptr(len(data)))
+	}
+	procGlobalUnlock.Call(handle)
+
+	if ret, _, err := procSetClipboardData.Call(format, handle); ret == 0 {
+		procGlobalFree.Call(handle)
+		return fmt.Errorf("set clipboard data: %w", err)
+	}
+	return nil
+}
+
+// decodeDIB 解码 24 位或 32 位的 CF_DIB 位图。位图的 alpha 通道常常全为 0，按不透明处理
+func decodeDIB(data []byte) (image.Image, error) {
+	var header bitmapInfoHeader
+	if err := binary.Read(bytes.NewReader(data), binary.LittleEndian, &header); err != nil {
+		return nil, fmt.Errorf("read bitmap header: %w", err)
+	}
+	if header.BitCount != 24 && header.BitCount != 32 {
+		return nil, fmt.Errorf("unsupported bitmap depth %d", header.BitCount)
+	}
+	if header.Compression != biRGB && header.Compression != biBitfields {
+		return nil, fmt.Errorf("unsupported bitmap compression %d", header.Compression)
+	}
+	// 尺寸和偏移来自其他程序写入的数据，以 int64 计算并在分配内存前检查，避免溢出
+	width64, height64 := int64(header.Width), int64(header.Height)
+	bottomUp := height64 > 0
+	if !bottomUp {
+		height64 = -height64
+	}
+	if width64 <= 0 || height64 <= 0 {
+		return nil, errors.New("empty bitmap")
+	}
+	if width64*height64 > clipboardMaxImagePixels {
+		return nil, fmt.Errorf("bitmap of %dx%d exceeds the %d pixel limit", width64, height64, clipboardMaxImagePixels)
+	}
+
+	offset64 := int64(header.Size)
+	if header.Compression == biBitfields && header.Size == 40 {
+		offset64 += 12 // 头部之后的三个颜色掩码
+	}
+	stride64 := (width64*int64(header.BitCount) + 31) / 32 * 4
+	if offset64+stride64*height64 > int64(len(data)) {
+		return nil, errors.New("truncated bitmap")
+	}
+	width, height, offset, stride := int(width64), int(height64), int(offset64), int(stride64)
+	bytesPerPixel := int(header.BitCount) / 8
+
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		row := y
+		if bottomUp {
+			row = height - 1 - y
+		}
+		pixels := data[offset+row*stride:]
+		for x := 0; x < width; x++ {
+			p := pixels[x*bytesPerPixel:]
+			img.SetNRGBA(x, y, color.NRGBA{R: p[2], G: p[1], B: p[0], A: 0xFF})
+		}
+	}
+	return img, nil
+}
+
+// encodeDIB 将图片编码为自下而上的 32 位 CF_DIB 位图
+func encodeDIB(img image.Image) []byte {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	header := bitmapInfoHeader{
+		Size:      40,
+		Width:     int32(width),
+		Height:    int32(height),
+		Planes:    1,
+		BitCount:  32,
+		SizeImage: uint32(width * height * 4),
+	}
+	var buf bytes.Buffer
+	buf.Grow(int(header.Size + header.SizeImage))
+	binary.Write(&buf, binary.LittleEndian, header)
+	for y := bounds.Max.Y - 1; y >= bounds.Min.Y; y-- {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			buf.Write([]byte{c.B, c.G, c.R, c.A})
+		}
+	}
+	return buf.Bytes()
+}
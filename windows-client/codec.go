@@ -0,0 +1,255 @@
+// codec.go
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/pion/rtp"
+	"github.com/pion/rtp/codecs"
+	"github.com/pion/webrtc/v3"
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+// 支持的视频编码，名称与 SDP rtpmap 中的编码名（小写）一致
+const (
+	codecH264 = "h264"
+	codecVP8  = "vp8"
+	codecVP9  = "vp9"
+	codecAV1  = "av1"
+)
+
+// videoCodec 描述一种视频编码：WebRTC 轨道能力、FFmpeg 编码参数以及 RTP 打包方式
+type videoCodec struct {
+	name       string
+	capability webrtc.RTPCodecCapability
+	// outputArgs 返回该编码专用的 FFmpeg 输出参数，包括编码器和输出格式
+	outputArgs func() ffmpeg.KwArgs
+	// newPayloader 创建 RTP 打包器使用的 Payloader
+	newPayloader func() rtp.Payloader
+	// newStream 创建将 FFmpeg 输出打包写入视频轨道的 videoStream
+	newStream func(packetizer rtp.Packetizer, track *webrtc.TrackLocalStaticRTP) videoStream
+}
+
+// videoCodecs 以编码名称为键的可用编码
+var videoCodecs = map[string]*videoCodec{
+	codecH264: {
+		name: codecH264,
+		// 与浏览器提供的 Constrained Baseline、packetization-mode=1 精确匹配
+		capability: webrtc.RTPCodecCapability{
+			MimeType:    webrtc.MimeTypeH264,
+			SDPFmtpLine: "level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f",
+		},
+		outputArgs: func() ffmpeg.KwArgs {
+			return ffmpeg.KwArgs{
+				"vcodec":      "libx264", // 使用 H.264 编码器
+				"preset":      config.Encoder.Preset,
+				"tune":        config.Encoder.Tune,
+				"profile:v":   "baseline", // 与 profile-level-id=42e01f 一致，x264 的 baseline 即 Constrained Baseline
+				"x264-params": "aud=1",    // 每帧前输出访问单元分隔符，便于确定帧边界
+				"f":           "h264",     // 输出裸 H.264 流（Annex B 格式）
+			}
+		},
+		newPayloader: func() rtp.Payloader { return &codecs.H264Payloader{} },
+		newStream: func(packetizer rtp.Packetizer, track *webrtc.TrackLocalStaticRTP) videoStream {
+			return newH264Stream(packetizer, track)
+		},
+	},
+	codecVP8: {
+		name:       codecVP8,
+		capability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP8},
+		outputArgs: func() ffmpeg.KwArgs {
+			return ffmpeg.KwArgs{
+				"vcodec":          "libvpx",
+				"deadline":        "realtime",
+				"cpu-used":        "8",
+				"lag-in-frames":   "0",
+				"error-resilient": "1",
+				"f":               "ivf",
+			}
+		},
+		newPayloader: func() rtp.Payloader { return &codecs.VP8Payloader{EnablePictureID: true} },
+		newStream: func(packetizer rtp.Packetizer, track *webrtc.TrackLocalStaticRTP) videoStream {
+			return newIVFStream(packetizer, track, isVP8Keyframe)
+		},
+	},
+	codecVP9: {
+		name:       codecVP9,
+		capability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeVP9, SDPFmtpLine: "profile-id=0"},
+		outputArgs: func() ffmpeg.KwArgs {
+			return ffmpeg.KwArgs{
+				"vcodec":          "libvpx-vp9",
+				"deadline":        "realtime",
+				"cpu-used":        "8",
+				"row-mt":          "1",
+				"lag-in-frames":   "0",
+				"error-resilient": "1",
+				"tune-content":    "screen", // 针对文字和界面优化，适合文字较多的屏幕
+				"f":               "ivf",
+			}
+		},
+		newPayloader: func() rtp.Payloader { return &codecs.VP9Payloader{} },
+		newStream: func(packetizer rtp.Packetizer, track *webrtc.TrackLocalStaticRTP) videoStream {
+			return newIVFStream(packetizer, track, isVP9Keyframe)
+		},
+	},
+	codecAV1: {
+		name:       codecAV1,
+		capability: webrtc.RTPCodecCapability{MimeType: webrtc.MimeTypeAV1},
+		outputArgs: func() ffmpeg.KwArgs {
+			if config.Encoder.AV1Encoder == "libsvtav1" {
+				return ffmpeg.KwArgs{
+					"vcodec": "libsvtav1",
+					"preset": "12", // 最快的预设，满足实时编码
+					"f":      "ivf",
+				}
+			}
+			return ffmpeg.KwArgs{
+				"vcodec":        "libaom-av1",
+				"usage":         "realtime",
+				"cpu-used":      "8",
+				"lag-in-frames": "0",
+				"f":             "ivf",
+			}
+		},
+		newPayloader: func() rtp.Payloader { return &codecs.AV1Payloader{} },
+		newStream: func(packetizer rtp.Packetizer, track *webrtc.TrackLocalStaticRTP) videoStream {
+			return newIVFStream(packetizer, track, isAV1Keyframe)
+		},
+	},
+}
+
+// av1Encoders FFmpeg 中可用的 AV1 编码器
+var av1Encoders = map[string]bool{"libaom-av1": true, "libsvtav1": true}
+
+// negotiateCodec 从 Viewer 的 Offer 中找出其支持的视频编码，按配置的优先顺序选择一个。
+// H.264 只接受与轨道能力匹配的格式（见 acceptsH264），不匹配时继续尝试 VP8、VP9 等其他编码。
+func negotiateCodec(offer webrtc.SessionDescription) (*videoCodec, error) {
+	parsed, err := offer.Unmarshal()
+	if err != nil {
+		return nil, fmt.Errorf("parse offer: %w", err)
+	}
+
+	offered := make(map[string]bool)
+	for _, media := range parsed.MediaDescriptions {
+		if media.MediaName.Media != "video" {
+			continue
+		}
+		for _, format := range media.MediaName.Formats {
+			payloadType, err := strconv.ParseUint(format, 10, 8)
+			if err != nil {
+				continue
+			}
+			codec, err := parsed.GetCodecForPayloadType(uint8(payloadType))
+			if err != nil {
+				continue
+			}
+			name := strings.ToLower(codec.Name)
+			if name == codecH264 && !acceptsH264(codec.Fmtp) {
+				continue
+			}
+			offered[name] = true
+		}
+	}
+
+	for _, name := range config.Encoder.Codecs {
+		if offered[name] {
+			return videoCodecs[name], nil
+		}
+	}
+	return nil, fmt.Errorf("viewer offered none of the enabled codecs %v", config.Encoder.Codecs)
+}
+
+// acceptsH264 判断 Offer 中的 H.264 格式能否与轨道绑定：packetization-mode=1，以便大帧可以分片发送；
+// profile-level-id 的 profile_idc 和 profile-iop 与轨道注册的相同（level 可以不同），与 pion 绑定轨道时的匹配规则一致
+func acceptsH264(fmtp string) bool {
+	params := parseFmtp(fmtp)
+	if params["packetization-mode"] != "1" {
+		return false
+	}
+	offered, err := hex.DecodeString(params["profile-level-id"])
+	if err != nil || len(offered) != 3 {
+		return false
+	}
+	registered, err := hex.DecodeString(parseFmtp(videoCodecs[codecH264].capability.SDPFmtpLine)["profile-level-id"])
+	if err != nil || len(registered) != 3 {
+		return false
+	}
+	return offered[0] == registered[0] && offered[1] == registered[1]
+}
+
+// parseFmtp 解析 fmtp 参数（以分号分隔的 key=value），参数名转为小写
+func parseFmtp(fmtp string) map[string]string {
+	params := make(map[string]string)
+	for _, param := range strings.Split(fmtp, ";") {
+		key, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+		if key != "" {
+			params[strings.ToLower(key)] = value
+		}
+	}
+	return params
+}
+
+// isVP8Keyframe 判断 VP8 帧是否为关键帧：帧标签第一位（P）为 0
+func isVP8Keyframe(frame []byte) bool {
+	return len(frame) > 0 && frame[0]&0x01 == 0
+}
+
+// isVP9Keyframe 解析 VP9 未压缩帧头，判断 frame_type 是否为 KEY_FRAME
+func isVP9Keyframe(frame []byte) bool {
+	if len(frame) == 0 || frame[0]>>6 != 0x2 { // frame_marker
+		return false
+	}
+	bit := func(i int) byte { return (frame[0] >> (7 - i)) & 1 }
+	profile := bit(2) | bit(3)<<1
+	i := 4
+	if profile == 3 {
+		i++ // reserved_zero
+	}
+	if bit(i) == 1 { // show_existing_frame
+		return false
+	}
+	return bit(i+1) == 0 // frame_type
+}
+
+// isAV1Keyframe 判断 AV1 时间单元是否以序列头开始，编码器只在关键帧前输出序列头
+func isAV1Keyframe(frame []byte) bool {
+	for len(frame) > 0 {
+		header := frame[0]
+		obuType := (header >> 3) & 0x0f
+		if obuType == 1 { // OBU_SEQUENCE_HEADER
+			return true
+		}
+		if header&0x02 == 0 { // 没有 obu_size，无法继续解析
+			return false
+		}
+		offset := 1
+		if header&0x04 != 0 { // obu_extension_flag
+			offset++
+		}
+		size, n := readLEB128(frame[min(offset, len(frame)):])
+		if n == 0 {
+			return false
+		}
+		next := offset + n + int(size)
+		if next > len(frame) || next <= 0 {
+			return false
+		}
+		frame = frame[next:]
+	}
+	return false
+}
+
+// readLEB128 读取 AV1 使用的 leb128 编码整数，返回值和占用的字节数
+func readLEB128(b []byte) (uint64, int) {
+	var value uint64
+	for i := 0; i < len(b) && i < 8; i++ {
+		value |= uint64(b[i]&0x7f) << (7 * i)
+		if b[i]&0x80 == 0 {
+			return value, i + 1
+		}
+	}
+	return 0, 0
+}
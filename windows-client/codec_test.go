@@ -0,0 +1,141 @@
+// codec_test.go
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// videoOffer 构造只包含一个视频媒体段的 Offer，formats 为各 PayloadType 的 rtpmap 和 fmtp
+func videoOffer(formats ...[2]string) webrtc.SessionDescription {
+	var payloadTypes []string
+	var attributes strings.Builder
+	for i, format := range formats {
+		payloadType := 96 + i
+		payloadTypes = append(payloadTypes, fmt.Sprint(payloadType))
+		fmt.Fprintf(&attributes, "a=rtpmap:%d %s\r\n", payloadType, format[0])
+		if format[1] != "" {
+			fmt.Fprintf(&attributes, "a=fmtp:%d %s\r\n", payloadType, format[1])
+		}
+	}
+	sdp := "v=0\r\n" +
+		"o=- 1 2 IN IP4 127.0.0.1\r\n" +
+		"s=-\r\n" +
+		"t=0 0\r\n" +
+		"m=video 9 UDP/TLS/RTP/SAVPF " + strings.Join(payloadTypes, " ") + "\r\n" +
+		"c=IN IP4 0.0.0.0\r\n" +
+		attributes.String()
+	return webrtc.SessionDescription{Type: webrtc.SDPTypeOffer, SDP: sdp}
+}
+
+func TestNegotiateCodec(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config = defaultConfig()
+	config.Encoder.Codecs = []string{codecH264, codecVP8, codecVP9}
+
+	vp8 := [2]string{"VP8/90000", ""}
+	vp9 := [2]string{"VP9/90000", "profile-id=0"}
+	h264 := func(fmtp string) [2]string { return [2]string{"H264/90000", fmtp} }
+
+	tests := []struct {
+		name    string
+		offer   webrtc.SessionDescription
+		want    string
+		wantErr bool
+	}{
+		{"constrained baseline", videoOffer(vp8, h264("level-asymmetry-allowed=1;packetization-mode=1;profile-level-id=42e01f")), codecH264, false},
+		{"constrained baseline with other level", videoOffer(h264("profile-level-id=42e034;packetization-mode=1")), codecH264, false},
+		{"upper-case profile-level-id", videoOffer(h264("packetization-mode=1;profile-level-id=42E01F")), codecH264, false},
+		{"packetization-mode 0 falls back", videoOffer(h264("packetization-mode=0;profile-level-id=42e01f"), vp8), codecVP8, false},
+		{"baseline without constraint falls back", videoOffer(h264("packetization-mode=1;profile-level-id=42001f"), vp8), codecVP8, false},
+		{"main profile falls back", videoOffer(h264("packetization-mode=1;profile-level-id=4d001f"), vp9), codecVP9, false},
+		{"high profile falls back", videoOffer(h264("packetization-mode=1;profile-level-id=640c1f"), vp8), codecVP8, false},
+		{"missing profile-level-id falls back", videoOffer(h264("packetization-mode=1"), vp8), codecVP8, false},
+		{"configured order wins over offer order", videoOffer(vp9, vp8), codecVP8, false},
+		{"disabled codec only", videoOffer([2]string{"AV1/90000", ""}), "", true},
+		{"incompatible H.264 only", videoOffer(h264("packetization-mode=1;profile-level-id=640c1f")), "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codec, err := negotiateCodec(tt.offer)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("negotiateCodec() = %s, want error", codec.name)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("negotiateCodec() error = %v", err)
+			}
+			if codec.name != tt.want {
+				t.Errorf("negotiateCodec() = %s, want %s", codec.name, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsVP8Keyframe(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+		want  bool
+	}{
+		{"key frame", []byte{0x10, 0x02, 0x00, 0x9d, 0x01, 0x2a}, true},
+		{"inter frame", []byte{0x31, 0x02, 0x00}, false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		if got := isVP8Keyframe(tt.frame); got != tt.want {
+			t.Errorf("%s: isVP8Keyframe() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsVP9Keyframe(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+		want  bool
+	}{
+		{"profile 0 key frame", []byte{0x82, 0x49, 0x83}, true},
+		{"profile 0 inter frame", []byte{0x86}, false},
+		{"show existing frame", []byte{0x88}, false},
+		{"profile 1 key frame", []byte{0xa0}, true},
+		{"profile 3 key frame", []byte{0xb0}, true},
+		{"profile 3 inter frame", []byte{0xb2}, false},
+		{"bad frame marker", []byte{0x00}, false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		if got := isVP9Keyframe(tt.frame); got != tt.want {
+			t.Errorf("%s: isVP9Keyframe() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsAV1Keyframe(t *testing.T) {
+	tests := []struct {
+		name  string
+		frame []byte
+		want  bool
+	}{
+		{"sequence header", []byte{0x0a, 0x01, 0x00}, true},
+		{"temporal delimiter then sequence header", []byte{0x12, 0x00, 0x0a, 0x01, 0x00}, true},
+		{"extension header then sequence header", []byte{0x16, 0x00, 0x00, 0x0a, 0x00}, true},
+		{"multi-byte size", []byte{0x32, 0x80, 0x01}, false},
+		{"frame only", []byte{0x12, 0x00, 0x32, 0x02, 0x10, 0x00}, false},
+		{"no size field", []byte{0x30, 0x0a, 0x01, 0x00}, false},
+		{"size beyond frame", []byte{0x12, 0x05, 0x0a, 0x00}, false},
+		{"truncated size", []byte{0x12, 0x80}, false},
+		{"empty", nil, false},
+	}
+	for _, tt := range tests {
+		if got := isAV1Keyframe(tt.frame); got != tt.want {
+			t.Errorf("%s: isAV1Keyframe() = %t, want %t", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,506 @@
+// config.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// Config 定义桌面客户端的配置，优先级：命令行参数 > 环境变量 > 配置文件 > 默认值
+type Config struct {
+	SignalURL          string             `json:"signal_url"`           // 信令服务器地址，例如 ws://192.168.40.100:8080/ws
+	SessionID          string             `json:"session_id"`           // 要加入的会话 ID
+	Token              string             `json:"token"`                // desktop 注册令牌
+	PingInterval       Duration           `json:"ping_interval"`        // 向信令服务器发送 WebSocket Ping 的间隔
+	PongTimeout        Duration           `json:"pong_timeout"`         // 超过该时间未收到 Pong 或消息即重连
+	ICEServers         []webrtc.ICEServer `json:"ice_servers"`          // 额外的 ICE 服务器，与信令服务器下发的合并使用
+	ICETransportPolicy string             `json:"ice_transport_policy"` // "all" 或 "relay"
+	ICERestartGrace    Duration           `json:"ice_restart_grace"`    // ICE 断开后等待多久发起 ICE 重启
+	ICERestartAttempts int                `json:"ice_restart_attempts"` // 连接恢复前最多发起的 ICE 重启次数
+	Capture            CaptureConfig      `json:"capture"`
+	Encoder            EncoderConfig      `json:"encoder"`
+	Input              InputConfig        `json:"input"`
+	Control            ControlConfig      `json:"control"`
+	Approval           ApprovalConfig     `json:"approval"`
+	Clipboard          ClipboardConfig    `json:"clipboard"`
+}
+
+// CaptureConfig 定义屏幕采集参数
+type CaptureConfig struct {
+	Backend   string `json:"backend"`   // 采集后端："gdigrab"、"x11grab"、"screenshot" 或 "testsrc"
+	Input     string `json:"input"`     // 后端输入：gdigrab 如 "desktop"，x11grab 如 ":0.0"，testsrc 为画面尺寸，screenshot 不使用；为空时使用默认值
+	Display   int    `json:"display"`   // 初始采集的显示器索引，-1 表示整个虚拟桌面，Viewer 可在会话中切换
+	Region    string `json:"region"`    // 初始采集的区域 "x,y,width,height"（虚拟桌面坐标），优先于 display
+	Window    string `json:"window"`    // 初始采集的窗口标题，优先于 region 和 display
+	Framerate int    `json:"framerate"` // 采集帧率
+}
+
+// EncoderConfig 定义视频编码参数
+type EncoderConfig struct {
+	Codecs     []string `json:"codecs"`      // 启用的视频编码，按优先顺序排列："h264"、"vp8"、"vp9"、"av1"
+	Preset     string   `json:"preset"`      // x264 预设，例如 "ultrafast"
+	Tune       string   `json:"tune"`        // x264 调优，例如 "zerolatency"
+	AV1Encoder string   `json:"av1_encoder"` // AV1 编码器："libaom-av1" 或 "libsvtav1"
+	GOP        int      `json:"gop"`         // 关键帧间隔（帧数）；PLI/FIR 请求的关键帧需另启 FFmpeg 进程，有数百毫秒延迟且交接期间 CPU 占用加倍，丢包多时可调小
+	Bitrate    int      `json:"bitrate"`     // 初始目标码率（kbps），关闭自适应时为固定码率
+	MinBitrate int      `json:"min_bitrate"` // 自适应码率下限（kbps）
+	MaxBitrate int      `json:"max_bitrate"` // 自适应码率上限（kbps）
+	Adaptive   bool     `json:"adaptive"`    // 根据 RTCP 反馈的带宽估计调整码率、分辨率和帧率
+}
+
+// InputConfig 定义输入注入参数
+type InputConfig struct {
+	Backend string `json:"backend"` // 输入注入后端："robotgo"、"uinput"（仅 Linux）或 "record"（只记录不注入）
+}
+
+// ControlConfig 定义 Viewer 的控制权限，令牌只允许观看的 Viewer 始终不能控制
+type ControlConfig struct {
+	ViewOnly bool   `json:"view_only"` // Viewer 连接后只能观看，需请求控制并获准
+	Requests string `json:"requests"`  // 控制请求的处理方式："prompt"（在控制台确认）、"grant"（自动允许）或 "deny"（拒绝）
+}
+
+// ApprovalConfig 定义新的 Viewer 连接前的本地确认
+type ApprovalConfig struct {
+	Mode      string   `json:"mode"`      // "prompt"（默认，在控制台或确认网页中确认）或 "auto"（直接接受，需显式开启）
+	Allowlist []string `json:"allowlist"` // 无需确认的 Viewer 令牌持有者（token 子命令的 -subject），不区分大小写
+	Timeout   Duration `json:"timeout"`   // 等待确认的时间，超时视为拒绝
+	Listen    string   `json:"listen"`    // 确认网页的监听地址，只允许本机地址，例如 127.0.0.1:8090；为空时不启用
+}
+
+// ClipboardConfig 定义剪贴板同步，只与有控制权限且开启同步的 Viewer 同步
+type ClipboardConfig struct {
+	Mode    string `json:"mode"`     // 同步方向："both"、"to_viewer"（只发给 Viewer）、"from_viewer"（只接收 Viewer）或 "off"
+	Images  bool   `json:"images"`   // 同步 PNG 图片，否则只同步文本
+	MaxSize int    `json:"max_size"` // 单次同步内容的最大字节数，超过时不同步
+}
+
+// Duration 支持在 JSON 中以 "30s"、"1m" 形式书写的时间间隔
+type Duration time.Duration
+
+// UnmarshalJSON 解析 time.ParseDuration 格式的字符串
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// MarshalJSON 以 time.Duration 的字符串形式输出
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// x264Presets x264 支持的预设
+var x264Presets = map[string]bool{
+	"ultrafast": true, "superfast": true, "veryfast": true, "faster": true, "fast": true,
+	"medium": true, "slow": true, "slower": true, "veryslow": true, "placebo": true,
+}
+
+// defaultConfig 返回默认配置
+func defaultConfig() *Config {
+	return &Config{
+		SignalURL:          "ws://192.168.40.100:8080/ws",
+		SessionID:          "default",
+		PingInterval:       Duration(20 * time.Second),
+		PongTimeout:        Duration(45 * time.Second),
+		ICETransportPolicy: "relay",
+		ICERestartGrace:    Duration(3 * time.Second),
+		ICERestartAttempts: 3,
+		Capture: CaptureConfig{
+			Backend:   defaultCaptureBackend(),
+			Display:   allDisplays,
+			Framerate: 30,
+		},
+		Encoder: EncoderConfig{
+			Codecs:     []string{codecH264, codecVP8, codecVP9, codecAV1},
+			Preset:     "ultrafast",
+			Tune:       "zerolatency",
+			AV1Encoder: "libaom-av1",
+			GOP:        300,
+			Bitrate:    1500,
+			MinBitrate: 300,
+			MaxBitrate: 4000,
+			Adaptive:   true,
+		},
+		Input: InputConfig{
+			Backend: inputRobotgo,
+		},
+		Control: ControlConfig{
+			Requests: controlRequestsPrompt,
+		},
+		Approval: ApprovalConfig{
+			Mode:    approvalPrompt,
+			Timeout: Duration(time.Minute),
+		},
+		Clipboard: ClipboardConfig{
+			Mode:    clipboardBoth,
+			MaxSize: 1 << 20,
+		},
+	}
+}
+
+// loadConfig 依次加载默认值、配置文件、环境变量和命令行参数，并校验最终配置
+func loadConfig(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("desktop-client", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("DESKTOP_CONFIG"), "JSON 配置文件路径（默认读取 DESKTOP_CONFIG）")
+	signalURL := fs.String("signal-url", "", "信令服务器地址，例如 ws://192.168.40.100:8080/ws")
+	sessionID := fs.String("session", "", "要加入的会话 ID")
+	token := fs.String("token", "", "信令服务器签发的 desktop 注册令牌")
+	pingInterval := fs.Duration("ping-interval", 0, "向信令服务器发送 WebSocket Ping 的间隔")
+	pongTimeout := fs.Duration("pong-timeout", 0, "超过该时间未收到 Pong 或消息即重连")
+	policy := fs.String("ice-transport-policy", "", "ICE 传输策略：all 或 relay")
+	restartGrace := fs.Duration("ice-restart-grace", 0, "ICE 断开后等待多久发起 ICE 重启")
+	restartAttempts := fs.Int("ice-restart-attempts", 0, "连接恢复前最多发起的 ICE 重启次数")
+	captureBackend := fs.String("capture-backend", "", "采集后端：gdigrab、x11grab、screenshot 或 testsrc")
+	captureInput := fs.String("capture-input", "", "采集输入，例如 gdigrab 的 desktop 或 x11grab 的 :0.0")
+	display := fs.Int("display", 0, "初始采集的显示器索引，-1 表示整个虚拟桌面")
+	region := fs.String("capture-region", "", "采集的区域 x,y,width,height")
+	window := fs.String("capture-window", "", "采集的窗口标题")
+	framerate := fs.Int("framerate", 0, "采集帧率")
+	codecs := fs.String("codecs", "", "启用的视频编码，按优先顺序以逗号分隔，例如 vp9,h264")
+	av1Encoder := fs.String("av1-encoder", "", "AV1 编码器：libaom-av1 或 libsvtav1")
+	gop := fs.Int("gop", 0, "关键帧间隔（帧数）")
+	preset := fs.String("preset", "", "x264 预设")
+	tune := fs.String("tune", "", "x264 调优参数")
+	bitrate := fs.Int("bitrate", 0, "初始目标码率（kbps），关闭自适应时为固定码率")
+	minBitrate := fs.Int("min-bitrate", 0, "自适应码率下限（kbps）")
+	maxBitrate := fs.Int("max-bitrate", 0, "自适应码率上限（kbps）")
+	inputBackend := fs.String("input-backend", "", "输入注入后端：robotgo、uinput 或 record")
+	viewOnly := fs.Bool("view-only", false, "Viewer 连接后只能观看，需请求控制并获准")
+	controlRequests := fs.String("control-requests", "", "控制请求的处理方式：prompt、grant 或 deny")
+	approval := fs.String("approval", "", "新 Viewer 连接的确认方式：prompt（默认）或 auto")
+	allowlist := fs.String("approval-allowlist", "", "无需确认的 Viewer 令牌持有者，以逗号分隔")
+	approvalTimeout := fs.Duration("approval-timeout", 0, "等待确认的时间，超时视为拒绝")
+	approvalListen := fs.String("approval-listen", "", "确认网页的本机监听地址，例如 127.0.0.1:8090")
+	clipboardMode := fs.String("clipboard", "", "剪贴板同步方向：both、to_viewer、from_viewer 或 off")
+	clipboardImages := fs.Bool("clipboard-images", false, "同步剪贴板中的 PNG 图片")
+	clipboardMaxSize := fs.Int("clipboard-max-size", 0, "单次同步剪贴板内容的最大字节数")
+	adaptive := fs.Bool("adaptive-bitrate", false, "根据 RTCP 反馈的带宽估计调整码率、分辨率和帧率")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	cfg := defaultConfig()
+	if *configPath != "" {
+		data, err := os.ReadFile(*configPath)
+		if err != nil {
+			return nil, fmt.Errorf("read config file: %w", err)
+		}
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, fmt.Errorf("parse config file %s: %w", *configPath, err)
+		}
+	}
+
+	if err := applyEnv(cfg); err != nil {
+		return nil, err
+	}
+
+	// 只覆盖命令行中显式指定的参数
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "signal-url":
+			cfg.SignalURL = *signalURL
+		case "session":
+			cfg.SessionID = *sessionID
+		case "token":
+			cfg.Token = *token
+		case "ping-interval":
+			cfg.PingInterval = Duration(*pingInterval)
+		case "pong-timeout":
+			cfg.PongTimeout = Duration(*pongTimeout)
+		case "ice-transport-policy":
+			cfg.ICETransportPolicy = *policy
+		case "ice-restart-grace":
+			cfg.ICERestartGrace = Duration(*restartGrace)
+		case "ice-restart-attempts":
+			cfg.ICERestartAttempts = *restartAttempts
+		case "capture-backend":
+			cfg.Capture.Backend = *captureBackend
+		case "capture-input":
+			cfg.Capture.Input = *captureInput
+		case "display":
+			cfg.Capture.Display = *display
+		case "capture-region":
+			cfg.Capture.Region = *region
+		case "capture-window":
+			cfg.Capture.Window = *window
+		case "framerate":
+			cfg.Capture.Framerate = *framerate
+		case "codecs":
+			cfg.Encoder.Codecs = splitList(*codecs)
+		case "av1-encoder":
+			cfg.Encoder.AV1Encoder = *av1Encoder
+		case "gop":
+			cfg.Encoder.GOP = *gop
+		case "preset":
+			cfg.Encoder.Preset = *preset
+		case "tune":
+			cfg.Encoder.Tune = *tune
+		case "bitrate":
+			cfg.Encoder.Bitrate = *bitrate
+		case "min-bitrate":
+			cfg.Encoder.MinBitrate = *minBitrate
+		case "max-bitrate":
+			cfg.Encoder.MaxBitrate = *maxBitrate
+		case "adaptive-bitrate":
+			cfg.Encoder.Adaptive = *adaptive
+		case "input-backend":
+			cfg.Input.Backend = *inputBackend
+		case "view-only":
+			cfg.Control.ViewOnly = *viewOnly
+		case "control-requests":
+			cfg.Control.Requests = *controlRequests
+		case "approval":
+			cfg.Approval.Mode = *approval
+		case "approval-allowlist":
+			cfg.Approval.Allowlist = splitList(*allowlist)
+		case "approval-timeout":
+			cfg.Approval.Timeout = Duration(*approvalTimeout)
+		case "approval-listen":
+			cfg.Approval.Listen = *approvalListen
+		case "clipboard":
+			cfg.Clipboard.Mode = *clipboardMode
+		case "clipboard-images":
+			cfg.Clipboard.Images = *clipboardImages
+		case "clipboard-max-size":
+			cfg.Clipboard.MaxSize = *clipboardMaxSize
+		}
+	})
+
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyEnv 使用 DESKTOP_* 环境变量覆盖配置
+func applyEnv(cfg *Config) error {
+	stringVars := map[string]*string{
+		"DESKTOP_SIGNAL_URL":           &cfg.SignalURL,
+		"DESKTOP_SESSION":              &cfg.SessionID,
+		"DESKTOP_TOKEN":                &cfg.Token,
+		"DESKTOP_ICE_TRANSPORT_POLICY": &cfg.ICETransportPolicy,
+		"DESKTOP_CAPTURE_BACKEND":      &cfg.Capture.Backend,
+		"DESKTOP_CAPTURE_INPUT":        &cfg.Capture.Input,
+		"DESKTOP_CAPTURE_REGION":       &cfg.Capture.Region,
+		"DESKTOP_CAPTURE_WINDOW":       &cfg.Capture.Window,
+		"DESKTOP_PRESET":               &cfg.Encoder.Preset,
+		"DESKTOP_TUNE":                 &cfg.Encoder.Tune,
+		"DESKTOP_AV1_ENCODER":          &cfg.Encoder.AV1Encoder,
+		"DESKTOP_INPUT_BACKEND":        &cfg.Input.Backend,
+		"DESKTOP_CONTROL_REQUESTS":     &cfg.Control.Requests,
+		"DESKTOP_APPROVAL":             &cfg.Approval.Mode,
+		"DESKTOP_APPROVAL_LISTEN":      &cfg.Approval.Listen,
+		"DESKTOP_CLIPBOARD":            &cfg.Clipboard.Mode,
+	}
+	for name, field := range stringVars {
+		if v, ok := os.LookupEnv(name); ok {
+			*field = v
+		}
+	}
+
+	intVars := map[string]*int{
+		"DESKTOP_ICE_RESTART_ATTEMPTS": &cfg.ICERestartAttempts,
+		"DESKTOP_DISPLAY":              &cfg.Capture.Display,
+		"DESKTOP_FRAMERATE":            &cfg.Capture.Framerate,
+		"DESKTOP_GOP":                  &cfg.Encoder.GOP,
+		"DESKTOP_BITRATE":              &cfg.Encoder.Bitrate,
+		"DESKTOP_MIN_BITRATE":          &cfg.Encoder.MinBitrate,
+		"DESKTOP_MAX_BITRATE":          &cfg.Encoder.MaxBitrate,
+		"DESKTOP_CLIPBOARD_MAX_SIZE":   &cfg.Clipboard.MaxSize,
+	}
+	for name, field := range intVars {
+		if v, ok := os.LookupEnv(name); ok {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: invalid integer %q", name, v)
+			}
+			*field = n
+		}
+	}
+
+	durationVars := map[string]*Duration{
+		"DESKTOP_PING_INTERVAL":     &cfg.PingInterval,
+		"DESKTOP_PONG_TIMEOUT":      &cfg.PongTimeout,
+		"DESKTOP_ICE_RESTART_GRACE": &cfg.ICERestartGrace,
+		"DESKTOP_APPROVAL_TIMEOUT":  &cfg.Approval.Timeout,
+	}
+	for name, field := range durationVars {
+		if v, ok := os.LookupEnv(name); ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				return fmt.Errorf("%s: invalid duration %q", name, v)
+			}
+			*field = Duration(d)
+		}
+	}
+
+	if v, ok := os.LookupEnv("DESKTOP_CODECS"); ok {
+		cfg.Encoder.Codecs = splitList(v)
+	}
+	if v, ok := os.LookupEnv("DESKTOP_APPROVAL_ALLOWLIST"); ok {
+		cfg.Approval.Allowlist = splitList(v)
+	}
+
+	boolVars := map[string]*bool{
+		"DESKTOP_ADAPTIVE_BITRATE": &cfg.Encoder.Adaptive,
+		"DESKTOP_VIEW_ONLY":        &cfg.Control.ViewOnly,
+		"DESKTOP_CLIPBOARD_IMAGES": &cfg.Clipboard.Images,
+	}
+	for name, field := range boolVars {
+		if v, ok := os.LookupEnv(name); ok {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s: invalid boolean %q", name, v)
+			}
+			*field = b
+		}
+	}
+	return nil
+}
+
+// validate 校验配置，返回所有不合法的字段
+func (c *Config) validate() error {
+	var errs []error
+	u, err := url.Parse(c.SignalURL)
+	if err != nil || (u.Scheme != "ws" && u.Scheme != "wss") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("signal_url %q must be a ws:// or wss:// URL", c.SignalURL))
+	}
+	if c.SessionID == "" {
+		errs = append(errs, errors.New("session_id must not be empty"))
+	}
+	if c.PingInterval <= 0 {
+		errs = append(errs, errors.New("ping_interval must be positive"))
+	}
+	if c.PongTimeout <= c.PingInterval {
+		errs = append(errs, errors.New("pong_timeout must be longer than ping_interval"))
+	}
+	if c.ICETransportPolicy != "all" && c.ICETransportPolicy != "relay" {
+		errs = append(errs, fmt.Errorf("ice_transport_policy %q must be all or relay", c.ICETransportPolicy))
+	}
+	for i, server := range c.ICEServers {
+		if len(server.URLs) == 0 {
+			errs = append(errs, fmt.Errorf("ice_servers[%d] has no urls", i))
+		}
+	}
+	if c.ICERestartGrace < 0 {
+		errs = append(errs, errors.New("ice_restart_grace must not be negative"))
+	}
+	if c.ICERestartAttempts < 0 {
+		errs = append(errs, fmt.Errorf("ice_restart_attempts %d must not be negative", c.ICERestartAttempts))
+	}
+	if _, err := newCapturer(c.Capture); err != nil {
+		errs = append(errs, fmt.Errorf("capture: %w", err))
+	}
+	if c.Capture.Display < allDisplays {
+		errs = append(errs, fmt.Errorf("capture.display %d must be a display index or -1 for the whole desktop", c.Capture.Display))
+	}
+	if _, err := initialTarget(c.Capture); err != nil {
+		errs = append(errs, fmt.Errorf("capture.region: %w", err))
+	}
+	if c.Capture.Framerate < 1 || c.Capture.Framerate > 120 {
+		errs = append(errs, fmt.Errorf("capture.framerate %d must be between 1 and 120", c.Capture.Framerate))
+	}
+	if len(c.Encoder.Codecs) == 0 {
+		errs = append(errs, errors.New("encoder.codecs must not be empty"))
+	}
+	seen := make(map[string]bool)
+	for _, name := range c.Encoder.Codecs {
+		if videoCodecs[name] == nil {
+			errs = append(errs, fmt.Errorf("encoder.codecs: unknown codec %q", name))
+		} else if seen[name] {
+			errs = append(errs, fmt.Errorf("encoder.codecs: duplicate codec %q", name))
+		}
+		seen[name] = true
+	}
+	if !av1Encoders[c.Encoder.AV1Encoder] {
+		errs = append(errs, fmt.Errorf("encoder.av1_encoder %q must be libaom-av1 or libsvtav1", c.Encoder.AV1Encoder))
+	}
+	if !x264Presets[c.Encoder.Preset] {
+		errs = append(errs, fmt.Errorf("encoder.preset %q is not a valid x264 preset", c.Encoder.Preset))
+	}
+	if c.Encoder.GOP < 1 {
+		errs = append(errs, fmt.Errorf("encoder.gop %d must be positive", c.Encoder.GOP))
+	}
+	if c.Encoder.MinBitrate < 50 {
+		errs = append(errs, fmt.Errorf("encoder.min_bitrate %d must be at least 50 kbps", c.Encoder.MinBitrate))
+	}
+	if c.Encoder.Bitrate < c.Encoder.MinBitrate || c.Encoder.Bitrate > c.Encoder.MaxBitrate {
+		errs = append(errs, fmt.Errorf("encoder.bitrate %d must be between min_bitrate %d and max_bitrate %d",
+			c.Encoder.Bitrate, c.Encoder.MinBitrate, c.Encoder.MaxBitrate))
+	}
+	if err := validInputBackend(c.Input.Backend); err != nil {
+		errs = append(errs, fmt.Errorf("input.backend: %w", err))
+	}
+	switch c.Control.Requests {
+	case controlRequestsPrompt, controlRequestsGrant, controlRequestsDeny:
+	default:
+		errs = append(errs, fmt.Errorf("control.requests %q must be prompt, grant or deny", c.Control.Requests))
+	}
+	if c.Approval.Mode != approvalAuto && c.Approval.Mode != approvalPrompt {
+		errs = append(errs, fmt.Errorf("approval.mode %q must be auto or prompt", c.Approval.Mode))
+	}
+	if c.Approval.Timeout <= 0 {
+		errs = append(errs, errors.New("approval.timeout must be positive"))
+	}
+	if c.Approval.Listen != "" && !loopbackAddr(c.Approval.Listen) {
+		errs = append(errs, fmt.Errorf("approval.listen %q must be a loopback address such as 127.0.0.1:8090", c.Approval.Listen))
+	}
+	switch c.Clipboard.Mode {
+	case clipboardBoth, clipboardToViewer, clipboardFromViewer, clipboardOff:
+	default:
+		errs = append(errs, fmt.Errorf("clipboard.mode %q must be both, to_viewer, from_viewer or off", c.Clipboard.Mode))
+	}
+	if c.Clipboard.MaxSize < 1 {
+		errs = append(errs, fmt.Errorf("clipboard.max_size %d must be positive", c.Clipboard.MaxSize))
+	}
+	return errors.Join(errs...)
+}
+
+// iceTransportPolicy 返回配置对应的 WebRTC ICE 传输策略
+func (c *Config) iceTransportPolicy() webrtc.ICETransportPolicy {
+	if c.ICETransportPolicy == "all" {
+		return webrtc.ICETransportPolicyAll
+	}
+	return webrtc.ICETransportPolicyRelay
+}
+
+// loopbackAddr 判断监听地址是否只能从本机访问
+func loopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// splitList 将逗号分隔的列表拆分为去除空白的小写元素
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.ToLower(strings.TrimSpace(item)); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,107 @@
+// console.go
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// consoleHelp 控制台命令说明
+const consoleHelp = `Commands:
+  viewers          list connected and waiting viewers
+  approve <viewer> accept a viewer waiting to connect
+  reject <viewer>  decline a viewer waiting to connect
+  grant <viewer>   allow a viewer to control the desktop
+  deny <viewer>    decline a viewer's pending control request
+  revoke <viewer>  make a viewer view only again`
+
+// runConsole 从标准输入读取桌面端操作者的命令，用于确认连接和控制请求、收回控制权限。
+// 标准输入关闭（例如作为服务运行）时返回。
+func runConsole() {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if err := runConsoleCommand(fields[0], fields[1:]); err != nil {
+			fmt.Println(err)
+		}
+	}
+}
+
+// runConsoleCommand 执行一条控制台命令
+func runConsoleCommand(command string, args []string) error {
+	switch command {
+	case "viewers":
+		listViewers()
+		return nil
+	case "approve", "reject":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: %s <viewer>", command)
+		}
+		return approvals.decide(args[0], command == "approve")
+	case "grant", "deny", "revoke":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: %s <viewer>", command)
+		}
+		peer := getPeer(args[0])
+		if peer == nil {
+			return fmt.Errorf("viewer %s not found", args[0])
+		}
+		granted := command == "grant"
+		if !peer.setControl(granted) {
+			return fmt.Errorf("viewer %s is view only", args[0])
+		}
+		if granted {
+			fmt.Printf("Viewer %s can now control the desktop.\n", args[0])
+		} else {
+			fmt.Printf("Viewer %s is now view only.\n", args[0])
+		}
+		return nil
+	case "help":
+		fmt.Println(consoleHelp)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q, type \"help\" for a list of commands", command)
+	}
+}
+
+// listViewers 打印等待确认的 Viewer，以及已连接的 Viewer 和其控制权限
+func listViewers() {
+	waiting := approvals.list()
+	for _, pending := range waiting {
+		fmt.Printf("%s  waiting for approval for %s\n",
+			describeViewer(pending.ViewerID, pending.Subject), time.Since(pending.Since).Round(time.Second))
+	}
+
+	mutex.Lock()
+	list := make([]*ViewerPeer, 0, len(peers))
+	for _, peer := range peers {
+		list = append(list, peer)
+	}
+	mutex.Unlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].viewerID < list[j].viewerID })
+
+	if len(list) == 0 && len(waiting) == 0 {
+		fmt.Println("No viewers connected.")
+		return
+	}
+	for _, peer := range list {
+		status := peer.controlStatus()
+		permission := "view only"
+		switch {
+		case status.Granted:
+			permission = "control"
+		case status.Locked:
+			permission = "view only (token)"
+		case status.Pending:
+			permission = "view only, control requested"
+		}
+		fmt.Printf("%s  %s\n", peer.viewerID, permission)
+	}
+}
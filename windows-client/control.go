@@ -0,0 +1,460 @@
+// control.go
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// controlProtocolVersion 当前二进制控制协议的版本
+const controlProtocolVersion = 2
+
+// controlAction 控制动作，二进制消息头中的动作编号
+type controlAction uint8
+
+// 控制动作及其二进制消息体：
+//
+//	mouse_move      x uint16, y uint16, 画面宽 uint16, 画面高 uint16
+//	mouse_down/up/click、double_click  按键 uint8（0 左键，1 中键，2 右键）
+//	mouse_wheel     水平格数 int16, 垂直格数 int16（正值向右、向下）
+//	key_down/up/press  修饰键 uint8（位 0 ctrl，1 shift，2 alt，3 cmd）, 按键码长度 uint8, W3C 按键码（KeyboardEvent.code）
+//	select_display  显示器索引 int16（-1 为整个桌面）
+//	capture_region  x int32, y int32, 宽 int32, 高 int32
+//	capture_window  标题长度 uint16, 标题 UTF-8
+//	type_text       文本长度 uint16, 文本 UTF-8
+//	request_control 无消息体，请求控制权限，桌面端通过 "control" 文本消息答复
+//	clipboard       格式 uint8（0 文本，1 PNG）, 是否最后一块 uint8, 长度 uint16, 内容片段
+//	clipboard_sync  是否同步剪贴板 uint8（0 关闭，1 开启）
+//
+// 版本 2 起按键以 W3C 按键码表示物理按键，输入法等产生的文本由 type_text 直接输入。
+const (
+	actionMouseMove controlAction = iota + 1
+	actionMouseDown
+	actionMouseUp
+	actionMouseClick
+	actionDoubleClick
+	actionMouseWheel
+	actionKeyDown
+	actionKeyUp
+	actionKeyPress
+	actionSelectDisplay
+	actionCaptureRegion
+	actionCaptureWindow
+	actionTypeText
+	actionRequestControl
+	actionClipboard
+	actionClipboardSync
+)
+
+// controlActionNames 动作名称，与 JSON 指令的 action 字段一致
+var controlActionNames = map[controlAction]string{
+	actionMouseMove:      "mouse_move",
+	actionMouseDown:      "mouse_down",
+	actionMouseUp:        "mouse_up",
+	actionMouseClick:     "mouse_click",
+	actionDoubleClick:    "double_click",
+	actionMouseWheel:     "mouse_wheel",
+	actionKeyDown:        "key_down",
+	actionKeyUp:          "key_up",
+	actionKeyPress:       "key_press",
+	actionSelectDisplay:  "select_display",
+	actionCaptureRegion:  "capture_region",
+	actionCaptureWindow:  "capture_window",
+	actionTypeText:       "type_text",
+	actionRequestControl: "request_control",
+	actionClipboard:      "clipboard",
+	actionClipboardSync:  "clipboard_sync",
+}
+
+func (a controlAction) String() string {
+	if name, ok := controlActionNames[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("action(%d)", uint8(a))
+}
+
+// mouseButtonNames 二进制协议中的鼠标按键编号，与浏览器 MouseEvent.button 一致
+var mouseButtonNames = []string{"left", "middle", "right"}
+
+// clipboardFormats 二进制协议中的剪贴板格式编号
+var clipboardFormats = []string{clipboardText, clipboardPNG}
+
+// modifierNames 二进制协议中修饰键位的名称，与 robotgo 的名称一致
+var modifierNames = []string{"ctrl", "shift", "alt", "cmd"}
+
+// ControlMessage 解码后的控制消息
+type ControlMessage struct {
+	Action    controlAction
+	Sequence  uint32    // Viewer 按发送顺序递增的序号，JSON 指令为 0
+	Timestamp time.Time // Viewer 发送时间，JSON 指令为收到的时间
+	Event     any       // 与动作对应的 *MouseMove、*MouseButton、*MouseWheel、*KeyEvent、*TextInput、*CaptureTarget、
+	// *ClipboardData、*ClipboardSync，request_control 为 nil
+}
+
+// MouseMove 鼠标移动到画面中的坐标；Width、Height 为 Viewer 端的画面尺寸，为 0 时坐标即采集区域中的像素
+type MouseMove struct {
+	X, Y          int
+	Width, Height int
+}
+
+// MouseButton 鼠标按键的按下、松开、单击或双击
+type MouseButton struct {
+	Button string // "left"、"middle" 或 "right"
+}
+
+// MouseWheel 滚轮滚动的格数，正值分别表示向右和向下
+type MouseWheel struct {
+	DeltaX, DeltaY int
+}
+
+// KeyEvent 按键的按下、松开或敲击。Key 为 W3C 按键码（见 keyCodes），
+// 旧版 JSON 指令中也可以是 robotgo 按键名。
+// 网页端将修饰键作为普通按键单独按下和松开，Modifiers 为空；
+// Modifiers 供 key_press 等一次发送组合键的指令使用，随该键一起按下和松开
+type KeyEvent struct {
+	Key       string
+	Modifiers []string
+}
+
+// TextInput 直接输入的 Unicode 文本，例如输入法上屏的文字，与桌面端的键盘布局无关
+type TextInput struct {
+	Text string
+}
+
+// ClipboardData Viewer 剪贴板内容的一块，较大的内容分多块依次发送，Final 的一块结束
+type ClipboardData struct {
+	Format string // "text" 或 "image/png"
+	Data   []byte
+	Final  bool
+}
+
+// ClipboardSync Viewer 开启或关闭本会话的剪贴板同步
+type ClipboardSync struct {
+	Enabled bool
+}
+
+// decodeControlMessage 解码 DataChannel 消息：二进制消息按控制协议严格校验，
+// 文本消息按旧版 JSON 指令解析，以兼容尚未升级的 Viewer
+func decodeControlMessage(data []byte, isString bool) (*ControlMessage, error) {
+	if isString {
+		return decodeJSONControl(data)
+	}
+	return decodeBinaryControl(data)
+}
+
+// decodeBinaryControl 解码二进制控制消息，长度、取值或版本不合法时返回错误。
+// 消息头依次为版本（uint8）、动作（uint8）、序号（uint32）和 Viewer 发送时间（uint64，Unix 毫秒），
+// 其后为动作对应的消息体，所有整数均为大端序。
+func decodeBinaryControl(data []byte) (*ControlMessage, error) {
+	r := &controlReader{data: data}
+	version := r.uint8()
+	action := controlAction(r.uint8())
+	sequence := r.uint32()
+	timestamp := r.uint64()
+	if r.err != nil {
+		return nil, fmt.Errorf("control message header: %w", r.err)
+	}
+	if version != controlProtocolVersion {
+		return nil, fmt.Errorf("unsupported control protocol version %d", version)
+	}
+
+	msg := &ControlMessage{
+		Action:    action,
+		Sequence:  sequence,
+		Timestamp: time.UnixMilli(int64(timestamp)),
+	}
+	switch action {
+	case actionMouseMove:
+		msg.Event = &MouseMove{
+			X:      int(r.uint16()),
+			Y:      int(r.uint16()),
+			Width:  int(r.uint16()),
+			Height: int(r.uint16()),
+		}
+	case actionMouseDown, actionMouseUp, actionMouseClick, actionDoubleClick:
+		button := int(r.uint8())
+		if r.err == nil && button >= len(mouseButtonNames) {
+			return nil, fmt.Errorf("%s: invalid mouse button %d", action, button)
+		}
+		if r.err == nil {
+			msg.Event = &MouseButton{Button: mouseButtonNames[button]}
+		}
+	case actionMouseWheel:
+		msg.Event = &MouseWheel{
+			DeltaX: int(int16(r.uint16())),
+			DeltaY: int(int16(r.uint16())),
+		}
+	case actionKeyDown, actionKeyUp, actionKeyPress:
+		flags := r.uint8()
+		key := r.string(int(r.uint8()))
+		if r.err == nil && flags>>len(modifierNames) != 0 {
+			return nil, fmt.Errorf("%s: invalid modifier flags %#x", action, flags)
+		}
+		if _, ok := keyCodes[key]; r.err == nil && !ok {
+			return nil, fmt.Errorf("%s: unknown key code %q", action, key)
+		}
+		event := &KeyEvent{Key: key}
+		for i, name := range modifierNames {
+			if flags&(1<<i) != 0 {
+				event.Modifiers = append(event.Modifiers, name)
+			}
+		}
+		msg.Event = event
+	case actionSelectDisplay:
+		msg.Event = &CaptureTarget{Type: targetDisplay, Display: int(int16(r.uint16()))}
+	case actionCaptureRegion:
+		msg.Event = &CaptureTarget{
+			Type:   targetRegion,
+			X:      int(int32(r.uint32())),
+			Y:      int(int32(r.uint32())),
+			Width:  int(int32(r.uint32())),
+			Height: int(int32(r.uint32())),
+		}
+	case actionCaptureWindow:
+		title := r.string(int(r.uint16()))
+		if r.err == nil && title == "" {
+			return nil, fmt.Errorf("%s: empty window title", action)
+		}
+		msg.Event = &CaptureTarget{Type: targetWindow, Title: title}
+	case actionTypeText:
+		text := r.string(int(r.uint16()))
+		if r.err == nil && text == "" {
+			return nil, fmt.Errorf("%s: empty text", action)
+		}
+		msg.Event = &TextInput{Text: text}
+	case actionRequestControl:
+	case actionClipboard:
+		format, final := int(r.uint8()), r.uint8()
+		data := r.bytes(int(r.uint16()))
+		if r.err == nil && format >= len(clipboardFormats) {
+			return nil, fmt.Errorf("%s: invalid format %d", action, format)
+		}
+		if r.err == nil && final > 1 {
+			return nil, fmt.Errorf("%s: invalid final flag %d", action, final)
+		}
+		if r.err == nil {
+			msg.Event = &ClipboardData{Format: clipboardFormats[format], Data: data, Final: final == 1}
+		}
+	case actionClipboardSync:
+		enabled := r.uint8()
+		if r.err == nil && enabled > 1 {
+			return nil, fmt.Errorf("%s: invalid flag %d", action, enabled)
+		}
+		msg.Event = &ClipboardSync{Enabled: enabled == 1}
+	default:
+		return nil, fmt.Errorf("unknown control action %d", uint8(action))
+	}
+	if r.err != nil {
+		return nil, fmt.Errorf("%s: %w", action, r.err)
+	}
+	if len(r.data) != 0 {
+		return nil, fmt.Errorf("%s: %d trailing byte(s)", action, len(r.data))
+	}
+	return msg, nil
+}
+
+// ControlCommand 旧版 Viewer 发送的 JSON 控制指令，参数均为字符串
+type ControlCommand struct {
+	Action string   `json:"action"` // 动作名称，见 controlActionNames
+	Params []string `json:"params"` // 参数，例如坐标、按键、滚动量、显示器索引、区域、窗口标题或文本
+}
+
+// decodeJSONControl 将旧版 JSON 指令转换为控制消息，参数的个数和取值与二进制协议同样校验
+func decodeJSONControl(data []byte) (*ControlMessage, error) {
+	var cmd ControlCommand
+	if err := json.Unmarshal(data, &cmd); err != nil {
+		return nil, fmt.Errorf("unmarshal control command: %w", err)
+	}
+	var action controlAction
+	for a, name := range controlActionNames {
+		if name == cmd.Action {
+			action = a
+		}
+	}
+	if action == 0 {
+		return nil, fmt.Errorf("unknown control action %q", cmd.Action)
+	}
+
+	params := cmd.Params
+	msg := &ControlMessage{Action: action, Timestamp: time.Now()}
+	switch action {
+	case actionMouseMove:
+		// 坐标，可附带 Viewer 端的画面宽高
+		if len(params) != 2 && len(params) != 4 {
+			return nil, fmt.Errorf("%s: expected 2 or 4 parameters, got %d", action, len(params))
+		}
+		values, err := atoiAll(params)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", action, err)
+		}
+		move := &MouseMove{X: values[0], Y: values[1]}
+		if len(values) == 4 {
+			move.Width, move.Height = values[2], values[3]
+		}
+		msg.Event = move
+	case actionMouseDown, actionMouseUp, actionMouseClick, actionDoubleClick:
+		// 按键名，省略时为左键
+		button := "left"
+		if len(params) > 0 {
+			button = params[0]
+		}
+		if len(params) > 1 || !slices.Contains(mouseButtonNames, button) {
+			return nil, fmt.Errorf("%s: invalid mouse button %v", action, params)
+		}
+		msg.Event = &MouseButton{Button: button}
+	case actionMouseWheel:
+		if len(params) != 2 {
+			return nil, fmt.Errorf("%s: expected 2 parameters, got %d", action, len(params))
+		}
+		values, err := atoiAll(params)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", action, err)
+		}
+		msg.Event = &MouseWheel{DeltaX: values[0], DeltaY: values[1]}
+	case actionKeyDown, actionKeyUp, actionKeyPress:
+		// 按键码或 robotgo 按键名，其后为按下的修饰键
+		if len(params) < 1 || params[0] == "" {
+			return nil, fmt.Errorf("%s: missing key", action)
+		}
+		for _, modifier := range params[1:] {
+			if !slices.Contains(modifierNames, modifier) {
+				return nil, fmt.Errorf("%s: invalid modifier %q", action, modifier)
+			}
+		}
+		msg.Event = &KeyEvent{Key: params[0], Modifiers: params[1:]}
+	case actionSelectDisplay:
+		if len(params) != 1 {
+			return nil, fmt.Errorf("%s: expected 1 parameter, got %d", action, len(params))
+		}
+		index, err := strconv.Atoi(params[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid display index %q", action, params[0])
+		}
+		msg.Event = &CaptureTarget{Type: targetDisplay, Display: index}
+	case actionCaptureRegion:
+		if len(params) != 4 {
+			return nil, fmt.Errorf("%s: expected 4 parameters, got %d", action, len(params))
+		}
+		region, err := parseRegion(strings.Join(params, ","))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", action, err)
+		}
+		msg.Event = &region
+	case actionCaptureWindow:
+		if len(params) != 1 || params[0] == "" {
+			return nil, fmt.Errorf("%s: expected a window title", action)
+		}
+		msg.Event = &CaptureTarget{Type: targetWindow, Title: params[0]}
+	case actionTypeText:
+		if len(params) != 1 || params[0] == "" {
+			return nil, fmt.Errorf("%s: expected a text", action)
+		}
+		msg.Event = &TextInput{Text: params[0]}
+	case actionRequestControl:
+		if len(params) != 0 {
+			return nil, fmt.Errorf("%s: expected no parameters, got %d", action, len(params))
+		}
+	case actionClipboard:
+		// JSON 指令只支持一次发送完整的文本
+		if len(params) != 1 {
+			return nil, fmt.Errorf("%s: expected a text", action)
+		}
+		msg.Event = &ClipboardData{Format: clipboardText, Data: []byte(params[0]), Final: true}
+	case actionClipboardSync:
+		if len(params) != 1 {
+			return nil, fmt.Errorf("%s: expected 1 parameter, got %d", action, len(params))
+		}
+		enabled, err := strconv.ParseBool(params[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid boolean %q", action, params[0])
+		}
+		msg.Event = &ClipboardSync{Enabled: enabled}
+	}
+	return msg, nil
+}
+
+// controlReader 按大端序依次读取二进制控制消息，出错后的读取均返回零值
+type controlReader struct {
+	data []byte
+	err  error
+}
+
+// errShortMessage 表示消息比其动作要求的长度短
+var errShortMessage = errors.New("message too short")
+
+// next 取出接下来的 n 个字节，不足时记录错误并返回 nil
+func (r *controlReader) next(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.data) < n {
+		r.err = errShortMessage
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *controlReader) uint8() uint8 {
+	if b := r.next(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *controlReader) uint16() uint16 {
+	if b := r.next(2); b != nil {
+		return binary.BigEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *controlReader) uint32() uint32 {
+	if b := r.next(4); b != nil {
+		return binary.BigEndian.Uint32(b)
+	}
+	return 0
+}
+
+func (r *controlReader) uint64() uint64 {
+	if b := r.next(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// bytes 读取 n 字节，返回的切片不引用消息缓冲区
+func (r *controlReader) bytes(n int) []byte {
+	return bytes.Clone(r.next(n))
+}
+
+// string 读取 n 字节的 UTF-8 字符串
+func (r *controlReader) string(n int) string {
+	b := r.next(n)
+	if r.err == nil && !utf8.Valid(b) {
+		r.err = errors.New("invalid UTF-8 string")
+	}
+	return string(b)
+}
+
+// atoiAll 将所有参数解析为整数
+func atoiAll(params []string) ([]int, error) {
+	values := make([]int, len(params))
+	for i, param := range params {
+		value, err := strconv.Atoi(param)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", param)
+		}
+		values[i] = value
+	}
+	return values, nil
+}
@@ -0,0 +1,227 @@
+// control_test.go
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+)
+
+// controlFixture 二进制控制协议的固定字节样例，网页端的 fronted/src/control.test.js 以同一文件校验 encodeControl
+type controlFixture struct {
+	Name     string `json:"name"`
+	Action   string `json:"action"`
+	Sequence uint32 `json:"sequence"`
+	Time     int64  `json:"time"`
+	Hex      string `json:"hex"`
+}
+
+func loadControlFixtures(t *testing.T) []controlFixture {
+	t.Helper()
+	data, err := os.ReadFile("testdata/control_v2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixtures []controlFixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		t.Fatal(err)
+	}
+	return fixtures
+}
+
+func TestDecodeBinaryControlFixtures(t *testing.T) {
+	want := map[string]any{
+		"mouse move":               &MouseMove{X: 100, Y: 200, Width: 1280, Height: 720},
+		"right button down":        &MouseButton{Button: "right"},
+		"double click":             &MouseButton{Button: "left"},
+		"wheel":                    &MouseWheel{DeltaX: -1, DeltaY: 3},
+		"modifier key down":        &KeyEvent{Key: "ControlLeft"},
+		"key up":                   &KeyEvent{Key: "KeyC"},
+		"key press with modifiers": &KeyEvent{Key: "KeyC", Modifiers: []string{"ctrl", "shift"}},
+		"whole desktop":            &CaptureTarget{Type: targetDisplay, Display: allDisplays},
+		"region":                   &CaptureTarget{Type: targetRegion, X: -1920, Y: 0, Width: 1920, Height: 1080},
+		"window":                   &CaptureTarget{Type: targetWindow, Title: "记事本"},
+		"text":                     &TextInput{Text: "héllo"},
+		"request control":          nil,
+		"clipboard image chunk":    &ClipboardData{Format: clipboardPNG, Data: []byte{0x89, 'P', 'N', 'G'}},
+		"clipboard text":           &ClipboardData{Format: clipboardText, Data: []byte("hi"), Final: true},
+		"clipboard sync":           &ClipboardSync{Enabled: true},
+	}
+
+	fixtures := loadControlFixtures(t)
+	if len(fixtures) != len(want) {
+		t.Errorf("got %d fixtures, want %d", len(fixtures), len(want))
+	}
+	for _, fixture := range fixtures {
+		t.Run(fixture.Name, func(t *testing.T) {
+			data, err := hex.DecodeString(fixture.Hex)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msg, err := decodeBinaryControl(data)
+			if err != nil {
+				t.Fatalf("decodeBinaryControl() error = %v", err)
+			}
+			if msg.Action.String() != fixture.Action {
+				t.Errorf("action = %s, want %s", msg.Action, fixture.Action)
+			}
+			if msg.Sequence != fixture.Sequence {
+				t.Errorf("sequence = %d, want %d", msg.Sequence, fixture.Sequence)
+			}
+			if msg.Timestamp.UnixMilli() != fixture.Time {
+				t.Errorf("timestamp = %d, want %d", msg.Timestamp.UnixMilli(), fixture.Time)
+			}
+			expected, ok := want[fixture.Name]
+			if !ok {
+				t.Fatalf("no expected event for fixture %q", fixture.Name)
+			}
+			if !reflect.DeepEqual(msg.Event, expected) {
+				t.Errorf("event = %#v, want %#v", msg.Event, expected)
+			}
+		})
+	}
+}
+
+// binaryControl 构造版本 2、序号 1 的二进制控制消息
+func binaryControl(action controlAction, body ...byte) []byte {
+	header := []byte{controlProtocolVersion, byte(action), 0, 0, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0}
+	return append(header, body...)
+}
+
+func TestDecodeBinaryControl(t *testing.T) {
+	key := func(flags byte, code string) []byte {
+		return append([]byte{flags, byte(len(code))}, code...)
+	}
+	withVersion := func(version byte, data []byte) []byte {
+		data[0] = version
+		return data
+	}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    any
+		wantErr bool
+	}{
+		{"largest coordinates", binaryControl(actionMouseMove, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff),
+			&MouseMove{X: 65535, Y: 65535, Width: 65535, Height: 65535}, false},
+		{"middle button", binaryControl(actionMouseUp, 1), &MouseButton{Button: "middle"}, false},
+		{"all modifiers", binaryControl(actionKeyDown, key(0x0f, "KeyA")...),
+			&KeyEvent{Key: "KeyA", Modifiers: []string{"ctrl", "shift", "alt", "cmd"}}, false},
+		{"clipboard sync off", binaryControl(actionClipboardSync, 0), &ClipboardSync{Enabled: false}, false},
+		{"empty clipboard chunk", binaryControl(actionClipboard, 0, 1, 0, 0), &ClipboardData{Format: clipboardText, Data: []byte{}, Final: true}, false},
+
+		{"empty message", nil, nil, true},
+		{"truncated header", binaryControl(actionRequestControl)[:13], nil, true},
+		{"version 1", withVersion(1, binaryControl(actionRequestControl)), nil, true},
+		{"future version", withVersion(3, binaryControl(actionRequestControl)), nil, true},
+		{"action 0", binaryControl(0), nil, true},
+		{"unknown action", binaryControl(17), nil, true},
+		{"truncated mouse move", binaryControl(actionMouseMove, 0, 1, 0, 2, 0, 3, 0), nil, true},
+		{"missing mouse button", binaryControl(actionMouseDown), nil, true},
+		{"invalid mouse button", binaryControl(actionMouseDown, 3), nil, true},
+		{"truncated wheel", binaryControl(actionMouseWheel, 0xff, 0xff), nil, true},
+		{"unknown key code", binaryControl(actionKeyDown, key(0, "Hyper")...), nil, true},
+		{"robotgo key name", binaryControl(actionKeyDown, key(0, "enter")...), nil, true},
+		{"empty key code", binaryControl(actionKeyUp, key(0, "")...), nil, true},
+		{"invalid modifier flags", binaryControl(actionKeyPress, key(0x10, "KeyA")...), nil, true},
+		{"key length beyond body", binaryControl(actionKeyDown, 0, 10, 'K', 'e', 'y'), nil, true},
+		{"truncated region", binaryControl(actionCaptureRegion, make([]byte, 15)...), nil, true},
+		{"empty window title", binaryControl(actionCaptureWindow, 0, 0), nil, true},
+		{"empty text", binaryControl(actionTypeText, 0, 0), nil, true},
+		{"text length beyond body", binaryControl(actionTypeText, 0, 5, 'a'), nil, true},
+		{"invalid clipboard format", binaryControl(actionClipboard, 2, 1, 0, 0), nil, true},
+		{"invalid clipboard final flag", binaryControl(actionClipboard, 0, 2, 0, 0), nil, true},
+		{"invalid clipboard sync flag", binaryControl(actionClipboardSync, 2), nil, true},
+		{"trailing bytes", binaryControl(actionRequestControl, 0), nil, true},
+		{"trailing bytes after key", binaryControl(actionKeyDown, append(key(0, "KeyA"), 0)...), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := decodeControlMessage(tt.data, false)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("decodeBinaryControl() = %#v, want error", msg.Event)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeBinaryControl() error = %v", err)
+			}
+			if msg.Sequence != 1 {
+				t.Errorf("sequence = %d, want 1", msg.Sequence)
+			}
+			if !reflect.DeepEqual(msg.Event, tt.want) {
+				t.Errorf("event = %#v, want %#v", msg.Event, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeJSONControl(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		wantAction controlAction
+		want       any
+		wantErr    bool
+	}{
+		{"move", `{"action":"mouse_move","params":["10","20"]}`, actionMouseMove, &MouseMove{X: 10, Y: 20}, false},
+		{"move with picture size", `{"action":"mouse_move","params":["10","20","1280","720"]}`,
+			actionMouseMove, &MouseMove{X: 10, Y: 20, Width: 1280, Height: 720}, false},
+		{"click defaults to left", `{"action":"mouse_click"}`, actionMouseClick, &MouseButton{Button: "left"}, false},
+		{"right button down", `{"action":"mouse_down","params":["right"]}`, actionMouseDown, &MouseButton{Button: "right"}, false},
+		{"wheel", `{"action":"mouse_wheel","params":["0","-2"]}`, actionMouseWheel, &MouseWheel{DeltaX: 0, DeltaY: -2}, false},
+		{"key code with modifiers", `{"action":"key_press","params":["KeyV","ctrl","shift"]}`,
+			actionKeyPress, &KeyEvent{Key: "KeyV", Modifiers: []string{"ctrl", "shift"}}, false},
+		{"robotgo key name", `{"action":"key_down","params":["enter"]}`, actionKeyDown, &KeyEvent{Key: "enter", Modifiers: []string{}}, false},
+		{"whole desktop", `{"action":"select_display","params":["-1"]}`, actionSelectDisplay, &CaptureTarget{Type: targetDisplay, Display: allDisplays}, false},
+		{"region", `{"action":"capture_region","params":["-1920","0","1920","1080"]}`,
+			actionCaptureRegion, &CaptureTarget{Type: targetRegion, X: -1920, Width: 1920, Height: 1080}, false},
+		{"window", `{"action":"capture_window","params":["记事本"]}`, actionCaptureWindow, &CaptureTarget{Type: targetWindow, Title: "记事本"}, false},
+		{"text", `{"action":"type_text","params":["héllo"]}`, actionTypeText, &TextInput{Text: "héllo"}, false},
+		{"request control", `{"action":"request_control"}`, actionRequestControl, nil, false},
+		{"clipboard text", `{"action":"clipboard","params":["hi"]}`, actionClipboard, &ClipboardData{Format: clipboardText, Data: []byte("hi"), Final: true}, false},
+		{"clipboard sync", `{"action":"clipboard_sync","params":["false"]}`, actionClipboardSync, &ClipboardSync{Enabled: false}, false},
+
+		{"not JSON", `mouse_move 10 20`, 0, nil, true},
+		{"unknown action", `{"action":"shutdown"}`, 0, nil, true},
+		{"missing action", `{"params":["10","20"]}`, 0, nil, true},
+		{"move with 3 parameters", `{"action":"mouse_move","params":["10","20","30"]}`, 0, nil, true},
+		{"move with non-integer", `{"action":"mouse_move","params":["10.5","20"]}`, 0, nil, true},
+		{"invalid mouse button", `{"action":"mouse_down","params":["side"]}`, 0, nil, true},
+		{"two mouse buttons", `{"action":"mouse_down","params":["left","right"]}`, 0, nil, true},
+		{"wheel with 1 parameter", `{"action":"mouse_wheel","params":["1"]}`, 0, nil, true},
+		{"missing key", `{"action":"key_down","params":[]}`, 0, nil, true},
+		{"empty key", `{"action":"key_up","params":[""]}`, 0, nil, true},
+		{"invalid modifier", `{"action":"key_press","params":["KeyA","win"]}`, 0, nil, true},
+		{"invalid display index", `{"action":"select_display","params":["first"]}`, 0, nil, true},
+		{"region too small", `{"action":"capture_region","params":["0","0","1","1"]}`, 0, nil, true},
+		{"empty window title", `{"action":"capture_window","params":[""]}`, 0, nil, true},
+		{"empty text", `{"action":"type_text","params":[""]}`, 0, nil, true},
+		{"request control with parameters", `{"action":"request_control","params":["now"]}`, 0, nil, true},
+		{"invalid clipboard sync flag", `{"action":"clipboard_sync","params":["maybe"]}`, 0, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := decodeControlMessage([]byte(tt.data), true)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("decodeJSONControl() = %s %#v, want error", msg.Action, msg.Event)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeJSONControl() error = %v", err)
+			}
+			if msg.Action != tt.wantAction || msg.Sequence != 0 {
+				t.Errorf("action = %s, sequence = %d, want %s, 0", msg.Action, msg.Sequence, tt.wantAction)
+			}
+			if !reflect.DeepEqual(msg.Event, tt.want) {
+				t.Errorf("event = %#v, want %#v", msg.Event, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+// display.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"log"
+
+	"github.com/kbinani/screenshot"
+	"github.com/pion/webrtc/v3"
+)
+
+// allDisplays 表示采集整个虚拟桌面（所有显示器的并集）
+const allDisplays = -1
+
+// Display 描述一个显示器，坐标为虚拟桌面坐标，主显示器位于原点
+type Display struct {
+	Index   int  `json:"index"`
+	X       int  `json:"x"`
+	Y       int  `json:"y"`
+	Width   int  `json:"width"`
+	Height  int  `json:"height"`
+	Primary bool `json:"primary"`
+}
+
+// bounds 返回显示器的区域
+func (d Display) bounds() image.Rectangle {
+	return image.Rect(d.X, d.Y, d.X+d.Width, d.Y+d.Height)
+}
+
+// DisplayList 通过 DataChannel 发送给 Viewer 的显示器列表和当前采集目标
+type DisplayList struct {
+	Type     string        `json:"type"` // "displays"
+	Displays []Display     `json:"displays"`
+	Target   CaptureTarget `json:"target"`
+}
+
+// screenDisplays 枚举系统中的显示器，位于原点的为主显示器
+func screenDisplays() ([]Display, error) {
+	n := screenshot.NumActiveDisplays()
+	if n == 0 {
+		return nil, fmt.Errorf("no active display")
+	}
+	list := make([]Display, n)
+	for i := range list {
+		bounds := screenshot.GetDisplayBounds(i)
+		list[i] = Display{
+			Index:   i,
+			X:       bounds.Min.X,
+			Y:       bounds.Min.Y,
+			Width:   bounds.Dx(),
+			Height:  bounds.Dy(),
+			Primary: bounds.Min == image.Point{},
+		}
+	}
+	return list, nil
+}
+
+// desktopBounds 返回所有显示器组成的虚拟桌面区域
+func desktopBounds(list []Display) image.Rectangle {
+	var area image.Rectangle
+	for _, display := range list {
+		area = area.Union(display.bounds())
+	}
+	return area
+}
+
+// sendDisplays 通过 DataChannel 向 Viewer 发送显示器列表
+func sendDisplays(peer *ViewerPeer) {
+	if peer.dataChannel.ReadyState() != webrtc.DataChannelStateOpen {
+		return
+	}
+	list, err := target.list()
+	if err != nil {
+		log.Println("Failed to enumerate displays:", err)
+		return
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		log.Println("Marshal display list failed:", err)
+		return
+	}
+	err = peer.dataChannel.SendText(string(data))
+	if err != nil {
+		log.Printf("Failed to send display list to viewer %s: %v", peer.viewerID, err)
+	}
+}
+
+// broadcastDisplays 向所有 Viewer 发送显示器列表
+func broadcastDisplays() {
+	mutex.Lock()
+	list := make([]*ViewerPeer, 0, len(peers))
+	for _, peer := range peers {
+		list = append(list, peer)
+	}
+	mutex.Unlock()
+
+	for _, peer := range list {
+		sendDisplays(peer)
+	}
+}
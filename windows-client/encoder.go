@@ -0,0 +1,461 @@
+// encoder.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+const (
+	encoderRestartDelay = time.Second     // FFmpeg 意外退出后重新启动前的等待时间
+	keyframeMinInterval = 2 * time.Second // 两次关键帧之间的最短间隔，合并短时间内的 PLI/FIR
+)
+
+// errEncoderStopped 表示编码器已停止，不再启动 FFmpeg
+var errEncoderStopped = errors.New("encoder stopped")
+
+// EncoderSettings 定义编码器运行时可调整的参数
+type EncoderSettings struct {
+	Bitrate   int     // 目标码率（kbps）
+	Scale     float64 // 相对采集分辨率的缩放比例，1 表示不缩放
+	Framerate int     // 采集和编码帧率
+}
+
+// videoStream 读取 FFmpeg 输出的码流，按帧打包为 RTP 写入视频轨道。
+// 打包状态跨 FFmpeg 进程保留；进程交接期间新旧两个进程的输出会同时读取，send 需可并发调用。
+type videoStream interface {
+	// send 读取码流并发送，直到码流结束；每帧发送前以该帧是否为关键帧调用 accept，返回 false 时丢弃该帧
+	send(r io.Reader, accept func(keyframe bool) bool) error
+	// lastKeyframe 返回最近一个关键帧的发送时间
+	lastKeyframe() time.Time
+	// keyframeRequested 在 Viewer 请求关键帧时调用，在新的关键帧到达之前做编码相关的处理
+	keyframeRequested()
+}
+
+// Encoder 运行 FFmpeg 采集屏幕并以一种视频编码输出，再打包为 RTP 写入该编码的视频轨道，
+// 协商到同一编码的 Viewer 共享该轨道。
+//
+// FFmpeg 命令行无法在运行中修改码率和分辨率，也没有让正在运行的编码器立即输出 IDR 的接口
+// （-force_key_frames 只能按启动时给定的时间或表达式生成关键帧），
+// 因此调整参数、切换采集区域或需要关键帧时，以当前参数另外启动一个 FFmpeg 进程，
+// 新进程的第一帧即是关键帧，从该帧起由新进程接替旧进程并结束旧进程。
+// 交接期间旧进程继续向所有 Viewer 发送画面，请求关键帧的 Viewer 之外的 Viewer 不会因此卡顿；
+// 打包器和时间戳在交接前后保持连续。
+type Encoder struct {
+	mutex           sync.Mutex
+	settings        EncoderSettings
+	codec           *videoCodec
+	capturer        Capturer
+	track           *webrtc.TrackLocalStaticRTP
+	stream          videoStream
+	active          *ffmpegProcess // 输出正在发送给 Viewer 的进程
+	standby         *ffmpegProcess // 以最新参数启动、等待其第一个关键帧后接替 active 的进程
+	keyframeTimer   *time.Timer    // 稍后检查等待中的关键帧请求
+	keyframePending time.Time      // 最早一个尚未完成的关键帧请求的时间，为零表示没有
+	stopped         bool           // 程序退出中，不再启动新进程
+}
+
+// ffmpegProcess 一个运行中的 FFmpeg 进程
+type ffmpegProcess struct {
+	cmd    *exec.Cmd
+	area   captureArea   // 启动时确定的采集区域
+	err    error         // 进程退出的原因，exited 关闭后可读
+	exited chan struct{} // 进程退出且输出读取完毕后关闭
+}
+
+// kill 结束进程，读取其输出的协程随后退出
+func (p *ffmpegProcess) kill() {
+	p.cmd.Process.Kill()
+}
+
+// encoderPool 按视频编码管理编码器：第一个协商到某种编码的 Viewer 连接时启动该编码的编码器，
+// 之后协商到同一编码的 Viewer 共享其输出，编码器运行到程序退出
+type encoderPool struct {
+	mutex    sync.Mutex
+	capturer Capturer
+	encoders map[string]*Encoder // 以编码名称为键
+	done     <-chan struct{}
+	wg       sync.WaitGroup
+}
+
+// encoders 全局编码器池，启动时创建
+var encoders *encoderPool
+
+// newEncoderPool 创建从指定采集后端读取画面的编码器池，编码器在 done 关闭时停止
+func newEncoderPool(capturer Capturer, done <-chan struct{}) *encoderPool {
+	return &encoderPool{
+		capturer: capturer,
+		encoders: make(map[string]*Encoder),
+		done:     done,
+	}
+}
+
+// get 返回指定编码的编码器，尚未创建时以初始参数创建并启动
+func (p *encoderPool) get(codec *videoCodec) (*Encoder, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if encoder, ok := p.encoders[codec.name]; ok {
+		return encoder, nil
+	}
+	encoder, err := newEncoder(codec, p.capturer, initialEncoderSettings())
+	if err != nil {
+		return nil, err
+	}
+	p.encoders[codec.name] = encoder
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		encoder.run(p.done)
+	}()
+	log.Printf("Started %s encoder.", codec.name)
+	return encoder, nil
+}
+
+// all 返回所有已启动的编码器
+func (p *encoderPool) all() []*Encoder {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	list := make([]*Encoder, 0, len(p.encoders))
+	for _, encoder := range p.encoders {
+		list = append(list, encoder)
+	}
+	return list
+}
+
+// wait 等待所有编码器停止
+func (p *encoderPool) wait() {
+	p.wg.Wait()
+}
+
+// newEncoder 创建从指定采集后端读取画面、以指定编码和初始参数编码的编码器
+func newEncoder(codec *videoCodec, capturer Capturer, settings EncoderSettings) (*Encoder, error) {
+	// 创建视频轨道；所有协商到该编码的 Viewer 的 PeerConnection 共享该轨道
+	track, err := webrtc.NewTrackLocalStaticRTP(codec.capability, "video", "desktop")
+	if err != nil {
+		return nil, fmt.Errorf("create %s video track: %w", codec.name, err)
+	}
+
+	// 创建 RTP Packetizer
+	payloadType := uint8(96) // 发送时由轨道替换为协商得到的 PayloadType
+	ssrc := rand.Uint32()    // 随机生成 SSRC
+
+	packetizer := rtp.NewPacketizer(
+		1200,        // MTU，最大传输单元
+		payloadType, // PayloadType
+		ssrc,        // SSRC，随机生成
+		codec.newPayloader(),
+		rtp.NewRandomSequencer(),
+		90000, // 时钟频率，视频通常为 90000
+	)
+	return &Encoder{
+		settings: settings,
+		codec:    codec,
+		capturer: capturer,
+		track:    track,
+		stream:   codec.newStream(packetizer, track),
+	}, nil
+}
+
+// Settings 返回编码器当前的参数
+func (e *Encoder) Settings() EncoderSettings {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.settings
+}
+
+// Reconfigure 以新参数启动新的 FFmpeg 进程接替当前进程，参数未变化时不做任何操作
+func (e *Encoder) Reconfigure(settings EncoderSettings) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if settings == e.settings {
+		return
+	}
+	log.Printf("Reconfiguring %s encoder: %d kbps, scale %.2f, %d fps (was %d kbps, scale %.2f, %d fps).",
+		e.codec.name, settings.Bitrate, settings.Scale, settings.Framerate,
+		e.settings.Bitrate, e.settings.Scale, e.settings.Framerate)
+	e.settings = settings
+	e.replace()
+}
+
+// RequestKeyframe 在 Viewer 发送 PLI/FIR 时调用，让其尽快收到可解码的画面：
+// H.264 立即重发缓存的 SPS/PPS，然后启动新的 FFmpeg 进程，以其第一个关键帧接替当前进程。
+// 新进程的启动和第一帧的编码需要数百毫秒（AV1 更久），交接期间两个进程同时编码，CPU 占用约为两倍，
+// 因此距上一个关键帧不足 keyframeMinInterval 的请求延后到间隔满足时再处理，多个请求合并为一次；
+// 请求在其之后发送了关键帧时才算完成，等待接替的进程未能接替时会重新启动。
+func (e *Encoder) RequestKeyframe() {
+	e.stream.keyframeRequested()
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.keyframePending.IsZero() {
+		e.keyframePending = time.Now()
+	}
+	e.scheduleKeyframe()
+}
+
+// scheduleKeyframe 处理等待中的关键帧请求：请求之后已发送关键帧时完成该请求；
+// 已有等待接替的进程或距上一个关键帧不足 keyframeMinInterval 时稍后再检查，否则启动新进程（调用方需持有 e.mutex）
+func (e *Encoder) scheduleKeyframe() {
+	if e.keyframePending.IsZero() || e.keyframeTimer != nil || e.stopped {
+		return
+	}
+	last := e.stream.lastKeyframe()
+	if last.After(e.keyframePending) {
+		e.keyframePending = time.Time{}
+		return
+	}
+	wait := keyframeMinInterval - time.Since(last)
+	switch {
+	case e.standby != nil:
+		// 等待接替的进程接替后即完成请求
+		wait = keyframeMinInterval
+	case wait <= 0:
+		log.Printf("Keyframe requested, starting a new %s encoder process.", e.codec.name)
+		e.replace()
+		// 新进程接替后由定时器确认请求已完成，未能接替时重新启动
+		wait = keyframeMinInterval
+	}
+	e.keyframeTimer = time.AfterFunc(wait, func() {
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		e.keyframeTimer = nil
+		e.scheduleKeyframe()
+	})
+}
+
+// CaptureChanged 在采集区域变化后调用，以新的区域启动 FFmpeg 进程接替当前进程
+func (e *Encoder) CaptureChanged() {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	log.Printf("Capture area changed, restarting %s encoder.", e.codec.name)
+	e.replace()
+}
+
+// replace 以当前参数启动新的 FFmpeg 进程，其第一个关键帧到达后接替当前进程；
+// 以旧参数启动、尚未接替的进程直接结束（调用方需持有 e.mutex）
+func (e *Encoder) replace() {
+	if e.active == nil && e.standby == nil {
+		// 没有运行中的进程，run 稍后会以当前参数启动
+		return
+	}
+	if e.standby != nil {
+		e.standby.kill()
+		e.standby = nil
+	}
+	proc, err := e.start()
+	if err != nil {
+		log.Printf("Failed to start new %s encoder process: %v", e.codec.name, err)
+		return
+	}
+	e.standby = proc
+}
+
+// accept 决定进程输出的一帧是否发送：当前进程的帧直接发送；
+// 等待接替的进程从第一个关键帧开始接替，并结束被接替的进程
+func (e *Encoder) accept(proc *ffmpegProcess, keyframe bool) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	switch {
+	case proc == e.active:
+		return true
+	case proc == e.standby && keyframe:
+		if e.active != nil {
+			e.active.kill()
+		}
+		e.active, e.standby = proc, nil
+		target.setCaptured(proc.area)
+		return true
+	}
+	return false
+}
+
+// run 启动 FFmpeg 并持续发送编码结果，直到 done 关闭；FFmpeg 意外退出时稍后重新启动
+func (e *Encoder) run(done <-chan struct{}) {
+	// 收到退出信号时结束所有 FFmpeg 进程
+	go func() {
+		<-done
+		e.mutex.Lock()
+		defer e.mutex.Unlock()
+		e.stopped = true
+		for _, proc := range []*ffmpegProcess{e.active, e.standby} {
+			if proc != nil {
+				proc.kill()
+			}
+		}
+	}()
+
+	for {
+		e.mutex.Lock()
+		proc, err := e.start()
+		if err == nil {
+			e.active = proc
+			target.setCaptured(proc.area)
+		}
+		e.mutex.Unlock()
+		if err == nil {
+			err = e.wait(proc)
+		}
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if err != nil {
+			log.Println("FFmpeg 进程出错:", err)
+		}
+		select {
+		case <-done:
+			return
+		case <-time.After(encoderRestartDelay):
+		}
+	}
+}
+
+// wait 等待发送画面的进程退出并返回退出原因；进程被接替时转而等待接替它的进程，
+// 当前进程意外退出但已有等待接替的进程时，等待该进程接替
+func (e *Encoder) wait(proc *ffmpegProcess) error {
+	for {
+		<-proc.exited
+		e.mutex.Lock()
+		switch {
+		case e.active != nil && e.active != proc:
+			proc = e.active
+		case e.standby != nil:
+			e.active = nil
+			proc = e.standby
+		default:
+			e.active = nil
+			e.mutex.Unlock()
+			return proc.err
+		}
+		e.mutex.Unlock()
+	}
+}
+
+// start 以当前参数启动一个 FFmpeg 进程，并在后台读取其输出直到进程退出（调用方需持有 e.mutex）
+func (e *Encoder) start() (*ffmpegProcess, error) {
+	if e.stopped {
+		return nil, errEncoderStopped
+	}
+	settings := e.settings
+	// 显示器可能已增减（例如 FFmpeg 因显示器被拔出而退出），启动前重新读取
+	if _, err := target.refreshDisplays(); err != nil {
+		return nil, fmt.Errorf("list displays: %w", err)
+	}
+	area, err := target.area()
+	if err != nil {
+		return nil, fmt.Errorf("get capture area: %w", err)
+	}
+	input, err := e.capturer.Input(settings.Framerate, area)
+	if err != nil {
+		return nil, fmt.Errorf("open capture input: %w", err)
+	}
+	cmd := ffmpegCommand(input, e.codec, settings)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("create FFmpeg pipe: %w", err)
+	}
+	// 由 Go 截屏的后端通过标准输入向 FFmpeg 提供原始帧
+	frames, _ := e.capturer.(frameWriter)
+	var stdin io.WriteCloser
+	if frames != nil {
+		stdin, err = cmd.StdinPipe()
+		if err != nil {
+			return nil, fmt.Errorf("create FFmpeg input pipe: %w", err)
+		}
+	}
+	err = cmd.Start()
+	if err != nil {
+		return nil, fmt.Errorf("start FFmpeg: %w", err)
+	}
+	proc := &ffmpegProcess{cmd: cmd, area: area, exited: make(chan struct{})}
+
+	if frames != nil {
+		go func() {
+			// FFmpeg 退出后写入失败，关闭标准输入使 FFmpeg 在截屏出错时结束
+			err := frames.writeFrames(stdin, settings.Framerate, area.bounds)
+			stdin.Close()
+			if err != nil {
+				log.Println("Stopped writing frames to FFmpeg:", err)
+			}
+		}()
+	}
+
+	go func() {
+		readErr := e.stream.send(stdout, func(keyframe bool) bool {
+			return e.accept(proc, keyframe)
+		})
+		if readErr != nil {
+			// 停止读取后 FFmpeg 会阻塞在写管道上，需先结束进程再等待
+			cmd.Process.Kill()
+		}
+		waitErr := cmd.Wait()
+
+		e.mutex.Lock()
+		if e.standby == proc {
+			e.standby = nil
+		}
+		e.mutex.Unlock()
+		proc.err = waitErr
+		if readErr != nil {
+			proc.err = readErr
+		}
+		close(proc.exited)
+	}()
+	return proc, nil
+}
+
+// ffmpegCommand 根据采集输入、视频编码和编码参数构造 FFmpeg 命令，将编码结果输出到标准输出
+func ffmpegCommand(input *ffmpeg.Stream, codec *videoCodec, settings EncoderSettings) *exec.Cmd {
+	output := ffmpeg.KwArgs{
+		"pix_fmt":  "yuv420p",
+		"g":        strconv.Itoa(config.Encoder.GOP),
+		"b:v":      fmt.Sprintf("%dk", settings.Bitrate),
+		"maxrate":  fmt.Sprintf("%dk", settings.Bitrate),
+		"bufsize":  fmt.Sprintf("%dk", settings.Bitrate/2), // 约半秒的缓冲，避免码率突发
+		"loglevel": "quiet",                                // 禁用 FFmpeg 日志输出，可根据需要调整
+		"copyts":   "",                                     // 保留输入的墙上时钟 PTS，IVF 输出据此确定采集时间
+		// 按比例缩放，宽高取偶数以满足 yuv420p 的要求（显示器或窗口尺寸可能为奇数）
+		"vf": fmt.Sprintf("scale=trunc(iw*%.2f/2)*2:trunc(ih*%.2f/2)*2", settings.Scale, settings.Scale),
+	}
+	for key, value := range codec.outputArgs() {
+		output[key] = value
+	}
+	return input.Output("pipe:1", output).Compile()
+}
+
+// rtpClock 将帧的时间换算为 90kHz 的 RTP 时间戳，跨 FFmpeg 进程保持连续。
+// IVF（VP8、VP9、AV1）使用帧头中的 PTS 换算出的采集时间（见 ivfCaptureTime）；
+// H.264 输出为不带 PTS 的 Annex B 码流，使用帧从管道中读出的时间，
+// 比实际采集晚一个编码和管道延迟，该延迟基本稳定，帧间隔仍与采集一致。
+type rtpClock struct {
+	startTime     time.Time // 第一帧的时间，时间戳以此为起点
+	baseTimestamp uint32    // 第一帧的 RTP 时间戳
+	lastTimestamp uint32
+}
+
+// timestamp 返回帧的采集或读出时间对应的 RTP 时间戳，保证严格递增
+func (c *rtpClock) timestamp(frameTime time.Time) uint32 {
+	if c.startTime.IsZero() {
+		c.startTime = frameTime
+		c.baseTimestamp = rand.Uint32() // 起始时间戳随机，符合 RFC 3550 的建议
+		c.lastTimestamp = c.baseTimestamp - 1
+	}
+	timestamp := c.baseTimestamp + uint32(frameTime.Sub(c.startTime).Microseconds()*9/100)
+	if int32(timestamp-c.lastTimestamp) <= 0 {
+		timestamp = c.lastTimestamp + 1
+	}
+	c.lastTimestamp = timestamp
+	return timestamp
+}
@@ -0,0 +1,43 @@
+// encoder_test.go
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRTPClock(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	var clock rtpClock
+	base := clock.timestamp(start)
+
+	tests := []struct {
+		name     string
+		readTime time.Time
+		want     uint32 // 相对第一帧时间戳的偏移
+	}{
+		{"one frame at 25 fps", start.Add(40 * time.Millisecond), 3600},
+		{"one second", start.Add(time.Second), 90000},
+		{"same read time stays increasing", start.Add(time.Second), 90001},
+		{"earlier read time stays increasing", start.Add(500 * time.Millisecond), 90002},
+		{"catches up with the clock", start.Add(2 * time.Second), 180000},
+	}
+	for _, tt := range tests {
+		if got := clock.timestamp(tt.readTime) - base; got != tt.want {
+			t.Errorf("%s: timestamp offset = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestRTPClockWraparound(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	clock := rtpClock{startTime: start, baseTimestamp: 0xfffffff0, lastTimestamp: 0xffffffef}
+
+	if got := clock.timestamp(start); got != 0xfffffff0 {
+		t.Errorf("first timestamp = %#x, want 0xfffffff0", got)
+	}
+	// 时间戳回绕后仍视为递增，不会被当作倒退而改为上一个值加一
+	if got := clock.timestamp(start.Add(time.Millisecond)); got != 0x4a {
+		t.Errorf("timestamp after wraparound = %#x, want 0x4a", got)
+	}
+}
@@ -0,0 +1,176 @@
+// h264stream.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media/h264reader"
+)
+
+// annexBStartCode 用于将同一访问单元的 NAL 单元拼接为 Annex B 码流
+var annexBStartCode = []byte{0x00, 0x00, 0x00, 0x01}
+
+// h264Stream 将 FFmpeg 输出的 NAL 单元按访问单元（一帧）分组后打包为 RTP，
+// 并保存跨 FFmpeg 进程的打包状态。同一帧的所有 RTP 包使用相同的时间戳，
+// 时间戳取自该帧第一个 NAL 单元从 FFmpeg 输出中读出的时间（而非采集时间，见 rtpClock），
+// 播放速度与实际采集速度一致，发送方报告（RTCP SR）中的 NTP/RTP 时间对应关系也随墙上时钟推进。
+type h264Stream struct {
+	mutex      sync.Mutex // 保护打包器、时钟和缓存的 SPS/PPS，交接期间两个进程的输出依次发送
+	packetizer rtp.Packetizer
+	track      *webrtc.TrackLocalStaticRTP
+	clock      rtpClock
+	sps        []byte
+	pps        []byte
+
+	resendParameterSets atomic.Bool  // 在下一帧之前重发缓存的 SPS/PPS
+	lastIDRTime         atomic.Int64 // 最近一个 IDR 帧的发送时间，Unix 纳秒
+}
+
+// h264AccessUnit 一个 FFmpeg 进程的输出中正在组装的访问单元
+type h264AccessUnit struct {
+	nals     [][]byte  // 待发送的 NAL 单元
+	readTime time.Time // 第一个 NAL 单元的读出时间
+	hasVCL   bool      // 是否已包含图像数据（slice）
+	idr      bool      // 是否为 IDR 帧
+	sps      []byte    // 访问单元中的 SPS
+	pps      []byte    // 访问单元中的 PPS
+}
+
+// newH264Stream 创建使用指定打包器、写入指定视频轨道的 H.264 流
+func newH264Stream(packetizer rtp.Packetizer, track *webrtc.TrackLocalStaticRTP) *h264Stream {
+	return &h264Stream{packetizer: packetizer, track: track}
+}
+
+// lastKeyframe 返回最近一个 IDR 帧的发送时间
+func (s *h264Stream) lastKeyframe() time.Time {
+	return time.Unix(0, s.lastIDRTime.Load())
+}
+
+// keyframeRequested 在下一帧之前重发缓存的 SPS/PPS，使 Viewer 在 IDR 帧到达前即可开始解码
+func (s *h264Stream) keyframeRequested() {
+	s.resendParameterSets.Store(true)
+}
+
+// send 读取 H.264 码流中的 NAL 单元，按帧打包为 RTP 写入视频轨道，直到码流结束；
+// FFmpeg 被结束时最后一帧可能不完整，直接丢弃
+func (s *h264Stream) send(r io.Reader, accept func(keyframe bool) bool) error {
+	// 使用 h264reader 读取 NAL 单元
+	h264Reader, err := h264reader.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("create H264 reader: %w", err)
+	}
+
+	unit := &h264AccessUnit{}
+	for {
+		// 读取 NAL 单元
+		nal, err := h264Reader.NextNAL()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("read NAL unit: %w", err)
+		}
+
+		if unit.startsAccessUnit(nal) {
+			s.flush(unit, accept)
+			unit = &h264AccessUnit{}
+		}
+		if len(unit.nals) == 0 {
+			unit.readTime = time.Now()
+		}
+
+		switch nal.UnitType {
+		case h264reader.NalUnitTypeAUD:
+			// 分隔符只用于判断帧边界，不需要发送
+			continue
+		case h264reader.NalUnitTypeSPS:
+			unit.sps = append([]byte{}, nal.Data...)
+		case h264reader.NalUnitTypePPS:
+			unit.pps = append([]byte{}, nal.Data...)
+		case h264reader.NalUnitTypeCodedSliceIdr:
+			unit.idr = true
+		}
+
+		unit.nals = append(unit.nals, nal.Data)
+		if isVCL(nal.UnitType) {
+			unit.hasVCL = true
+		}
+	}
+}
+
+// startsAccessUnit 判断 NAL 单元是否开始新的访问单元（H.264 7.4.1.2.3）：
+// 在已有 slice 之后出现的 AUD、SEI、SPS、PPS，或 first_mb_in_slice 为 0 的 slice
+func (u *h264AccessUnit) startsAccessUnit(nal *h264reader.NAL) bool {
+	if !u.hasVCL {
+		return false
+	}
+	switch {
+	case nal.UnitType == h264reader.NalUnitTypeAUD,
+		nal.UnitType == h264reader.NalUnitTypeSEI,
+		nal.UnitType == h264reader.NalUnitTypeSPS,
+		nal.UnitType == h264reader.NalUnitTypePPS,
+		nal.UnitType >= 14 && nal.UnitType <= 18:
+		return true
+	case nal.UnitType == h264reader.NalUnitTypeCodedSliceNonIdr,
+		nal.UnitType == h264reader.NalUnitTypeCodedSliceDataPartitionA,
+		nal.UnitType == h264reader.NalUnitTypeCodedSliceIdr:
+		// first_mb_in_slice 为 ue(v) 编码，值为 0 时 slice header 的第一位是 1
+		return len(nal.Data) > 1 && nal.Data[1]&0x80 != 0
+	}
+	return false
+}
+
+// isVCL 判断 NAL 单元是否为图像数据（slice）
+func isVCL(unitType h264reader.NalUnitType) bool {
+	return unitType >= h264reader.NalUnitTypeCodedSliceNonIdr && unitType <= h264reader.NalUnitTypeCodedSliceIdr
+}
+
+// flush 将访问单元打包为 RTP 包写入视频轨道，所有包使用同一时间戳，最后一个包带 marker 位；
+// accept 返回 false 时丢弃该访问单元。IDR 帧之前必须带有 SPS 和 PPS，
+// 收到关键帧请求后也在下一帧之前重发，访问单元未包含时加入缓存的 SPS/PPS。
+func (s *h264Stream) flush(unit *h264AccessUnit, accept func(keyframe bool) bool) {
+	if len(unit.nals) == 0 {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !accept(unit.idr) {
+		return
+	}
+	if unit.sps != nil {
+		s.sps = unit.sps
+	}
+	if unit.pps != nil {
+		s.pps = unit.pps
+	}
+
+	var payload []byte
+	resend := s.resendParameterSets.Swap(false)
+	if (unit.idr || resend) && unit.sps == nil && unit.pps == nil && s.sps != nil && s.pps != nil {
+		for _, nal := range [][]byte{s.sps, s.pps} {
+			payload = append(payload, annexBStartCode...)
+			payload = append(payload, nal...)
+		}
+	}
+	for _, nal := range unit.nals {
+		payload = append(payload, annexBStartCode...)
+		payload = append(payload, nal...)
+	}
+	if unit.idr {
+		s.lastIDRTime.Store(time.Now().UnixNano())
+	}
+	timestamp := s.clock.timestamp(unit.readTime)
+	for _, packet := range s.packetizer.Packetize(payload, 0) {
+		packet.Timestamp = timestamp
+		if err := s.track.WriteRTP(packet); err != nil {
+			log.Println("发送 RTP 包时出错:", err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// h264stream_test.go
+package main
+
+import (
+	"testing"
+
+	"github.com/pion/webrtc/v3/pkg/media/h264reader"
+)
+
+func TestStartsAccessUnit(t *testing.T) {
+	nal := func(unitType h264reader.NalUnitType, data ...byte) *h264reader.NAL {
+		return &h264reader.NAL{UnitType: unitType, Data: append([]byte{byte(unitType)}, data...)}
+	}
+
+	tests := []struct {
+		name   string
+		hasVCL bool
+		nal    *h264reader.NAL
+		want   bool
+	}{
+		{"SPS before any slice", false, nal(h264reader.NalUnitTypeSPS), false},
+		{"first slice of first unit", false, nal(h264reader.NalUnitTypeCodedSliceIdr, 0x88), false},
+		{"AUD after slice", true, nal(h264reader.NalUnitTypeAUD, 0xf0), true},
+		{"SEI after slice", true, nal(h264reader.NalUnitTypeSEI, 0x05), true},
+		{"SPS after slice", true, nal(h264reader.NalUnitTypeSPS, 0x42), true},
+		{"PPS after slice", true, nal(h264reader.NalUnitTypePPS, 0xce), true},
+		{"prefix NAL after slice", true, nal(14), true},
+		{"IDR slice with first_mb_in_slice 0", true, nal(h264reader.NalUnitTypeCodedSliceIdr, 0x88), true},
+		{"non-IDR slice with first_mb_in_slice 0", true, nal(h264reader.NalUnitTypeCodedSliceNonIdr, 0x9a), true},
+		{"second slice of same picture", true, nal(h264reader.NalUnitTypeCodedSliceNonIdr, 0x40), false},
+		{"truncated slice", true, nal(h264reader.NalUnitTypeCodedSliceNonIdr), false},
+		{"filler data after slice", true, nal(12), false},
+		{"end of sequence after slice", true, nal(h264reader.NalUnitTypeEndOfSequence), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			unit := &h264AccessUnit{hasVCL: tt.hasVCL}
+			if got := unit.startsAccessUnit(tt.nal); got != tt.want {
+				t.Errorf("startsAccessUnit() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,159 @@
+// icerestart.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// iceRestartTimeout 发出 ICE 重启 Offer 后等待连接恢复的时间，超时后再次重启
+const iceRestartTimeout = 10 * time.Second
+
+// onICEStateChange 根据 ICE 状态调度 ICE 重启：断开后等待宽限期再重启，失败时立即重启，
+// 连接恢复后取消重启；重启次数用尽仍未恢复时关闭该 Viewer 的连接
+func (p *ViewerPeer) onICEStateChange(state webrtc.ICEConnectionState) {
+	switch state {
+	case webrtc.ICEConnectionStateConnected, webrtc.ICEConnectionStateCompleted:
+		p.restartMutex.Lock()
+		if p.restartTimer != nil {
+			p.restartTimer.Stop()
+			p.restartTimer = nil
+		}
+		p.restartAttempts = 0
+		p.restartMutex.Unlock()
+	case webrtc.ICEConnectionStateDisconnected:
+		p.scheduleICERestart(time.Duration(config.ICERestartGrace))
+	case webrtc.ICEConnectionStateFailed:
+		p.scheduleICERestart(0)
+	}
+}
+
+// scheduleICERestart 在 delay 之后发起 ICE 重启，已有待执行的重启时不重复调度
+func (p *ViewerPeer) scheduleICERestart(delay time.Duration) {
+	p.restartMutex.Lock()
+	defer p.restartMutex.Unlock()
+	if p.closed || p.restartTimer != nil {
+		return
+	}
+	p.restartTimer = time.AfterFunc(delay, p.restartICE)
+}
+
+// restartICE 向 Viewer 发送带 ICERestart 的新 Offer，保留现有的媒体轨道和 DataChannel
+func (p *ViewerPeer) restartICE() {
+	p.restartMutex.Lock()
+	p.restartTimer = nil
+	if p.closed {
+		p.restartMutex.Unlock()
+		return
+	}
+	state := p.peerConnection.ICEConnectionState()
+	if state == webrtc.ICEConnectionStateConnected || state == webrtc.ICEConnectionStateCompleted {
+		p.restartAttempts = 0
+		p.restartMutex.Unlock()
+		return
+	}
+	if p.restartAttempts >= config.ICERestartAttempts {
+		p.restartMutex.Unlock()
+		log.Printf("ICE restart for viewer %s gave up after %d attempts, closing.", p.viewerID, config.ICERestartAttempts)
+		go closePeer(p.viewerID)
+		return
+	}
+	p.restartMutex.Unlock()
+
+	// 上一次重启的 Answer 未返回时先回滚本地 Offer
+	if p.peerConnection.SignalingState() == webrtc.SignalingStateHaveLocalOffer {
+		err := p.peerConnection.SetLocalDescription(webrtc.SessionDescription{Type: webrtc.SDPTypeRollback})
+		if err != nil {
+			log.Printf("Rollback local offer for viewer %s failed: %v", p.viewerID, err)
+		}
+	}
+
+	offer, err := p.peerConnection.CreateOffer(&webrtc.OfferOptions{ICERestart: true})
+	if err != nil {
+		log.Printf("CreateOffer with ICE restart for viewer %s failed: %v", p.viewerID, err)
+		p.retryICERestart()
+		return
+	}
+	err = p.peerConnection.SetLocalDescription(offer)
+	if err != nil {
+		log.Printf("SetLocalDescription for ICE restart of viewer %s failed: %v", p.viewerID, err)
+		p.retryICERestart()
+		return
+	}
+	offerJSON, err := json.Marshal(p.peerConnection.LocalDescription())
+	if err != nil {
+		log.Println("Marshal ICE restart offer failed:", err)
+		p.retryICERestart()
+		return
+	}
+	err = sendMessage(client, Message{
+		Type:     "offer",
+		ViewerID: p.viewerID,
+		Payload:  json.RawMessage(offerJSON),
+	})
+	if errors.Is(err, errNotConnected) {
+		// 信令断开时不计入重启次数，重新注册成功后由 restartStalledPeers 继续
+		log.Printf("ICE restart for viewer %s deferred until signaling reconnects.", p.viewerID)
+		return
+	}
+
+	p.restartMutex.Lock()
+	p.restartAttempts++
+	log.Printf("Sent ICE restart offer to viewer %s (attempt %d).", p.viewerID, p.restartAttempts)
+	p.restartMutex.Unlock()
+	p.scheduleICERestart(iceRestartTimeout)
+}
+
+// retryICERestart 将未能发出的 ICE 重启计入重启次数并稍后重试。
+// ICE 状态已是断开或失败，不会再次变化触发重启，次数用尽时由 restartICE 关闭连接。
+func (p *ViewerPeer) retryICERestart() {
+	p.restartMutex.Lock()
+	p.restartAttempts++
+	p.restartMutex.Unlock()
+	p.scheduleICERestart(iceRestartTimeout)
+}
+
+// stopICERestart 取消待执行的 ICE 重启，在关闭连接时调用
+func (p *ViewerPeer) stopICERestart() {
+	p.restartMutex.Lock()
+	defer p.restartMutex.Unlock()
+	p.closed = true
+	if p.restartTimer != nil {
+		p.restartTimer.Stop()
+		p.restartTimer = nil
+	}
+}
+
+// restartStalledPeers 在重新注册到信令服务器后，立即为所有未连接的 Viewer 发起 ICE 重启
+func restartStalledPeers() {
+	mutex.Lock()
+	stalled := make([]*ViewerPeer, 0, len(peers))
+	for _, peer := range peers {
+		state := peer.peerConnection.ICEConnectionState()
+		if state != webrtc.ICEConnectionStateConnected && state != webrtc.ICEConnectionStateCompleted {
+			stalled = append(stalled, peer)
+		}
+	}
+	mutex.Unlock()
+
+	for _, peer := range stalled {
+		peer.scheduleICERestart(0)
+	}
+}
+
+// dtlsFingerprint 提取 SDP 中的 DTLS 指纹；Viewer 新建 PeerConnection 时指纹会变化，
+// 据此区分重协商 Offer 与全新连接的 Offer
+func dtlsFingerprint(sdp string) string {
+	for _, line := range strings.Split(sdp, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "a=fingerprint:") {
+			return strings.TrimPrefix(line, "a=fingerprint:")
+		}
+	}
+	return ""
+}
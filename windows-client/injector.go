@@ -0,0 +1,67 @@
+// injector.go
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// 输入注入后端
+const (
+	inputRobotgo = "robotgo" // robotgo 注入，按键优先按物理键码注入
+	inputUinput  = "uinput"  // Linux /dev/uinput 虚拟输入设备，不依赖 X11 的 XTest 扩展，文本通过 xdotool 输入
+	inputRecord  = "record"  // 只记录并打印输入事件而不注入，用于调试控制协议
+)
+
+// inputBackends 支持的输入注入后端
+var inputBackends = []string{inputRobotgo, inputUinput, inputRecord}
+
+// InputInjector 输入注入后端，将 Viewer 的控制消息注入本机
+type InputInjector interface {
+	// MoveMouse 将鼠标移动到虚拟桌面坐标
+	MoveMouse(x, y int) error
+	// MouseButton 按下或松开鼠标按键："left"、"middle" 或 "right"
+	MouseButton(button string, down bool) error
+	// MouseWheel 滚动滚轮的格数，正值分别表示向右和向下
+	MouseWheel(deltaX, deltaY int) error
+	// Key 按下或松开按键，key 为 W3C 按键码或 robotgo 按键名，修饰键随按键一起按下和松开
+	Key(key string, modifiers []string, down bool) error
+	// TypeText 输入 Unicode 文本
+	TypeText(text string) error
+}
+
+// injector 全局输入注入后端，启动时创建
+var injector InputInjector
+
+// newInputInjector 根据配置创建输入注入后端，uinput 按采集后端枚举的虚拟桌面换算绝对坐标
+func newInputInjector(cfg InputConfig, capturer Capturer) (InputInjector, error) {
+	switch cfg.Backend {
+	case inputRobotgo:
+		return robotgoInjector{}, nil
+	case inputUinput:
+		list, err := capturer.Displays()
+		if err != nil {
+			return nil, err
+		}
+		return newUinputInjector(desktopBounds(list))
+	case inputRecord:
+		return &recordingInjector{logEvents: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown input backend %q", cfg.Backend)
+	}
+}
+
+// validInputBackend 判断输入注入后端在当前平台是否可用
+func validInputBackend(backend string) error {
+	switch backend {
+	case inputRobotgo, inputRecord:
+		return nil
+	case inputUinput:
+		if runtime.GOOS != "linux" {
+			return fmt.Errorf("input backend %q is only supported on Linux", backend)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown input backend %q, expected one of %v", backend, inputBackends)
+	}
+}
@@ -0,0 +1,72 @@
+// injector_record.go
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+)
+
+// recordingInjector 只记录输入事件而不注入，可用于调试控制协议，或在测试中检查控制消息的解析和坐标换算
+type recordingInjector struct {
+	mutex     sync.Mutex
+	events    []string
+	logEvents bool // 同时打印到日志
+}
+
+// Events 返回已记录的事件，例如 "move 100,200"、"button left down"、"key KeyA ctrl+shift up"
+func (r *recordingInjector) Events() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.events...)
+}
+
+// Reset 清空已记录的事件
+func (r *recordingInjector) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = nil
+}
+
+func (r *recordingInjector) record(format string, args ...any) error {
+	event := fmt.Sprintf(format, args...)
+	if r.logEvents {
+		log.Println("Input:", event)
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, event)
+	return nil
+}
+
+func (r *recordingInjector) MoveMouse(x, y int) error {
+	return r.record("move %d,%d", x, y)
+}
+
+func (r *recordingInjector) MouseButton(button string, down bool) error {
+	return r.record("button %s %s", button, upDown(down))
+}
+
+func (r *recordingInjector) MouseWheel(deltaX, deltaY int) error {
+	return r.record("wheel %d,%d", deltaX, deltaY)
+}
+
+func (r *recordingInjector) Key(key string, modifiers []string, down bool) error {
+	if len(modifiers) > 0 {
+		return r.record("key %s %s %s", key, strings.Join(modifiers, "+"), upDown(down))
+	}
+	return r.record("key %s %s", key, upDown(down))
+}
+
+func (r *recordingInjector) TypeText(text string) error {
+	return r.record("text %q", text)
+}
+
+// upDown 返回按下或松开的描述
+func upDown(down bool) string {
+	if down {
+		return "down"
+	}
+	return "up"
+}
@@ -0,0 +1,81 @@
+// injector_robotgo.go
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/go-vgo/robotgo"
+)
+
+// robotgoButtons 控制协议中的鼠标按键名与 robotgo 按键名的对应关系
+var robotgoButtons = map[string]string{
+	"left":   "left",
+	"middle": "center",
+	"right":  "right",
+}
+
+// robotgoInjector 使用 robotgo 注入输入；键码表中的按键按物理位置注入（Windows 扫描码、X11 XTest 键码），
+// 平台不支持按键码注入时退回其 robotgo 按键名
+type robotgoInjector struct{}
+
+func (robotgoInjector) MoveMouse(x, y int) error {
+	robotgo.MoveMouse(x, y)
+	return nil
+}
+
+func (robotgoInjector) MouseButton(button string, down bool) error {
+	name, ok := robotgoButtons[button]
+	if !ok {
+		return fmt.Errorf("unknown mouse button %q", button)
+	}
+	if down {
+		return robotgo.MouseDown(name)
+	}
+	return robotgo.MouseUp(name)
+}
+
+func (robotgoInjector) MouseWheel(deltaX, deltaY int) error {
+	// robotgo 的正值表示向左和向上
+	robotgo.Scroll(-deltaX, -deltaY)
+	return nil
+}
+
+func (robotgoInjector) Key(key string, modifiers []string, down bool) error {
+	name := key
+	if native, ok := keyCodes[key]; ok {
+		err := injectKeys(keySequence(native, modifiers, down), down)
+		if !errors.Is(err, errKeyCodesUnsupported) {
+			return err
+		}
+		if native.name == "" {
+			return fmt.Errorf("key %s has no robotgo equivalent", key)
+		}
+		name = native.name
+	}
+
+	// 旧版 Viewer 发送的按键名直接交给 robotgo
+	args := make([]interface{}, len(modifiers))
+	for i, modifier := range modifiers {
+		args[i] = modifier
+	}
+	if down {
+		return robotgo.KeyDown(name, args...)
+	}
+	return robotgo.KeyUp(name, args...)
+}
+
+func (robotgoInjector) TypeText(text string) error {
+	robotgo.TypeStr(text)
+	return nil
+}
+
+// injectKeys 依次按下或松开物理按键
+func injectKeys(keys []nativeKey, down bool) error {
+	for _, key := range keys {
+		if err := injectKey(key, down); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+// injector_uinput_linux.go
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+// uinput 的 ioctl 请求（linux/uinput.h）
+const (
+	uiSetEvBit  = 0x40045564
+	uiSetKeyBit = 0x40045565
+	uiSetRelBit = 0x40045566
+	uiSetAbsBit = 0x40045567
+	uiDevCreate = 0x5501
+)
+
+// 输入事件类型和编码（linux/input-event-codes.h）
+const (
+	evSyn      = 0x00
+	evKey      = 0x01
+	evRel      = 0x02
+	evAbs      = 0x03
+	synReport  = 0x00
+	relHWheel  = 0x06
+	relWheel   = 0x08
+	absX       = 0x00
+	absY       = 0x01
+	busVirtual = 0x06
+)
+
+// uinputButtons 控制协议中的鼠标按键名与 evdev 按键编码的对应关系
+var uinputButtons = map[string]uint16{
+	"left":   0x110, // BTN_LEFT
+	"right":  0x111, // BTN_RIGHT
+	"middle": 0x112, // BTN_MIDDLE
+}
+
+// uinputUserDev 与内核 struct uinput_user_dev 的内存布局一致
+type uinputUserDev struct {
+	Name         [80]byte
+	ID           struct{ Bustype, Vendor, Product, Version uint16 }
+	FFEffectsMax uint32
+	AbsMax       [64]int32
+	AbsMin       [64]int32
+	AbsFuzz      [64]int32
+	AbsFlat      [64]int32
+}
+
+// inputEvent 与内核 struct input_event 的内存布局一致
+type inputEvent struct {
+	Time  syscall.Timeval
+	Type  uint16
+	Code  uint16
+	Value int32
+}
+
+// uinputInjector 通过 /dev/uinput 创建虚拟键盘和绝对坐标鼠标注入输入，按键按 evdev 键码注入，
+// 不依赖 X11 的 XTest 扩展，也适用于 Wayland。需要 /dev/uinput 的写权限（通常为 root 或 input 组）。
+// evdev 没有文本输入，TypeText 交给 xdotool。
+type uinputInjector struct {
+	mutex   sync.Mutex
+	device  *os.File
+	desktop image.Rectangle // 创建设备时的虚拟桌面，绝对坐标的范围与之对应
+}
+
+// newUinputInjector 创建覆盖虚拟桌面 desktop 的虚拟输入设备
+func newUinputInjector(desktop image.Rectangle) (InputInjector, error) {
+	device, err := os.OpenFile("/dev/uinput", os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("open uinput: %w", err)
+	}
+
+	var keys []uint16
+	for _, key := range keyCodes {
+		keys = append(keys, key.evdev)
+	}
+	for _, button := range uinputButtons {
+		keys = append(keys, button)
+	}
+	setBits := []struct {
+		request uintptr
+		values  []uint16
+	}{
+		{uiSetEvBit, []uint16{evSyn, evKey, evRel, evAbs}},
+		{uiSetKeyBit, keys},
+		{uiSetRelBit, []uint16{relWheel, relHWheel}},
+		{uiSetAbsBit, []uint16{absX, absY}},
+	}
+	fd := device.Fd()
+	for _, bits := range setBits {
+		for _, value := range bits.values {
+			if err := ioctl(fd, bits.request, uintptr(value)); err != nil {
+				device.Close()
+				return nil, fmt.Errorf("configure uinput device: %w", err)
+			}
+		}
+	}
+
+	var dev uinputUserDev
+	copy(dev.Name[:], "go-webrtc remote input")
+	dev.ID.Bustype = busVirtual
+	dev.AbsMax[absX] = int32(max(desktop.Dx()-1, 1))
+	dev.AbsMax[absY] = int32(max(desktop.Dy()-1, 1))
+	if err := binary.Write(device, binary.NativeEndian, &dev); err != nil {
+		device.Close()
+		return nil, fmt.Errorf("set up uinput device: %w", err)
+	}
+	if err := ioctl(fd, uiDevCreate, 0); err != nil {
+		device.Close()
+		return nil, fmt.Errorf("create uinput device: %w", err)
+	}
+	return &uinputInjector{device: device, desktop: desktop}, nil
+}
+
+// ioctl 执行 ioctl 系统调用
+func ioctl(fd, request, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// emit 写入一组输入事件，并以 SYN_REPORT 结束
+func (u *uinputInjector) emit(events ...inputEvent) error {
+	events = append(events, inputEvent{Type: evSyn, Code: synReport})
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	return binary.Write(u.device, binary.NativeEndian, events)
+}
+
+func (u *uinputInjector) MoveMouse(x, y int) error {
+	return u.emit(
+		inputEvent{Type: evAbs, Code: absX, Value: int32(x - u.desktop.Min.X)},
+		inputEvent{Type: evAbs, Code: absY, Value: int32(y - u.desktop.Min.Y)},
+	)
+}
+
+func (u *uinputInjector) MouseButton(button string, down bool) error {
+	code, ok := uinputButtons[button]
+	if !ok {
+		return fmt.Errorf("unknown mouse button %q", button)
+	}
+	return u.emit(inputEvent{Type: evKey, Code: code, Value: keyValue(down)})
+}
+
+func (u *uinputInjector) MouseWheel(deltaX, deltaY int) error {
+	// REL_WHEEL 的正值表示向上
+	return u.emit(
+		inputEvent{Type: evRel, Code: relHWheel, Value: int32(deltaX)},
+		inputEvent{Type: evRel, Code: relWheel, Value: int32(-deltaY)},
+	)
+}
+
+func (u *uinputInjector) Key(key string, modifiers []string, down bool) error {
+	native, ok := lookupKey(key)
+	if !ok {
+		return fmt.Errorf("unknown key %q", key)
+	}
+	var events []inputEvent
+	for _, k := range keySequence(native, modifiers, down) {
+		events = append(events, inputEvent{Type: evKey, Code: k.evdev, Value: keyValue(down)})
+	}
+	return u.emit(events...)
+}
+
+func (u *uinputInjector) TypeText(text string) error {
+	if _, err := exec.LookPath("xdotool"); err != nil {
+		return errors.New("typing text with the uinput backend requires xdotool")
+	}
+	if out, err := exec.Command("xdotool", "type", "--", text).CombinedOutput(); err != nil {
+		return fmt.Errorf("xdotool type: %w: %s", err, out)
+	}
+	return nil
+}
+
+// keyValue 返回按键事件的值：1 为按下，0 为松开
+func keyValue(down bool) int32 {
+	if down {
+		return 1
+	}
+	return 0
+}
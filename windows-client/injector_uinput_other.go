@@ -0,0 +1,14 @@
+//go:build !linux
+
+// injector_uinput_other.go
+package main
+
+import (
+	"fmt"
+	"image"
+)
+
+// newUinputInjector uinput 只在 Linux 上可用
+func newUinputInjector(desktop image.Rectangle) (InputInjector, error) {
+	return nil, fmt.Errorf("input backend %q is only supported on Linux", inputUinput)
+}
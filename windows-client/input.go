@@ -0,0 +1,127 @@
+// input.go
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// handleControlMessage 解码并执行 Viewer 通过 DataChannel 发送的控制消息。
+// 二进制消息的序号必须递增，重复或倒退的消息被丢弃。
+func (p *ViewerPeer) handleControlMessage(data webrtc.DataChannelMessage) {
+	msg, err := decodeControlMessage(data.Data, data.IsString)
+	if err != nil {
+		log.Printf("Invalid control message from viewer %s: %v", p.viewerID, err)
+		return
+	}
+	// 控制请求之外的消息都需要控制权限，只能观看的 Viewer 不能操作鼠标键盘或切换采集目标
+	if msg.Action == actionRequestControl {
+		p.requestControl()
+		return
+	}
+	if !p.canControl() {
+		log.Printf("Dropped %s from view-only viewer %s.", msg.Action, p.viewerID)
+		return
+	}
+	if !data.IsString {
+		p.controlMutex.Lock()
+		stale := p.hasSequence && int32(msg.Sequence-p.lastSequence) <= 0
+		if !stale {
+			p.lastSequence, p.hasSequence = msg.Sequence, true
+		}
+		p.controlMutex.Unlock()
+		if stale {
+			log.Printf("Dropped stale %s #%d from viewer %s.", msg.Action, msg.Sequence, p.viewerID)
+			return
+		}
+	}
+	// 剪贴板消息不经过输入后端
+	switch event := msg.Event.(type) {
+	case *ClipboardData:
+		p.receiveClipboard(event)
+		return
+	case *ClipboardSync:
+		p.controlMutex.Lock()
+		p.clipboardSync = event.Enabled && config.Clipboard.Mode != clipboardOff
+		p.controlMutex.Unlock()
+		log.Printf("Viewer %s set clipboard sync: %t.", p.viewerID, event.Enabled)
+		return
+	}
+	if err := applyControl(injector, msg); err != nil {
+		log.Printf("Failed to %s for viewer %s: %v", msg.Action, p.viewerID, err)
+	}
+}
+
+// applyControl 通过输入后端执行解码后的控制消息，单击和双击以按下、松开的序列注入
+func applyControl(in InputInjector, msg *ControlMessage) error {
+	switch event := msg.Event.(type) {
+	case *MouseMove:
+		bounds, err := target.inputBounds()
+		if err != nil {
+			return fmt.Errorf("get capture area: %w", err)
+		}
+		point := mapMousePosition(event, bounds)
+		return in.MoveMouse(point.X, point.Y)
+	case *MouseButton:
+		switch msg.Action {
+		case actionMouseDown:
+			return in.MouseButton(event.Button, true)
+		case actionMouseUp:
+			return in.MouseButton(event.Button, false)
+		case actionMouseClick:
+			return clickMouse(in, event.Button, 1)
+		case actionDoubleClick:
+			return clickMouse(in, event.Button, 2)
+		}
+	case *MouseWheel:
+		return in.MouseWheel(event.DeltaX, event.DeltaY)
+	case *KeyEvent:
+		switch msg.Action {
+		case actionKeyDown:
+			return in.Key(event.Key, event.Modifiers, true)
+		case actionKeyUp:
+			return in.Key(event.Key, event.Modifiers, false)
+		case actionKeyPress:
+			if err := in.Key(event.Key, event.Modifiers, true); err != nil {
+				return err
+			}
+			return in.Key(event.Key, event.Modifiers, false)
+		}
+	case *TextInput:
+		return in.TypeText(event.Text)
+	case *CaptureTarget:
+		return target.selectTarget(*event)
+	}
+	return nil
+}
+
+// clickMouse 按下并松开鼠标按键 count 次
+func clickMouse(in InputInjector, button string, count int) error {
+	for i := 0; i < count; i++ {
+		if err := in.MouseButton(button, true); err != nil {
+			return err
+		}
+		if err := in.MouseButton(button, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mapMousePosition 将画面中的坐标换算为采集区域 bounds 中的虚拟桌面坐标，
+// 超出画面的坐标限制在采集区域之内，鼠标不会移出所采集的显示器、区域或窗口
+func mapMousePosition(move *MouseMove, bounds image.Rectangle) image.Point {
+	x, y := move.X, move.Y
+	if move.Width > 0 && move.Height > 0 {
+		// Viewer 端的画面可能经过缩放
+		x = x * bounds.Dx() / move.Width
+		y = y * bounds.Dy() / move.Height
+	}
+	// 转换为所采集的显示器、区域或窗口在虚拟桌面中的坐标
+	x = min(max(bounds.Min.X+x, bounds.Min.X), bounds.Max.X-1)
+	y = min(max(bounds.Min.Y+y, bounds.Min.Y), bounds.Max.Y-1)
+	return image.Pt(x, y)
+}
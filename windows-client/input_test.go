@@ -0,0 +1,95 @@
+// input_test.go
+package main
+
+import (
+	"image"
+	"reflect"
+	"testing"
+)
+
+func TestMapMousePosition(t *testing.T) {
+	second := image.Rect(1920, 0, 3840, 1080) // 位于主显示器右侧的第二个显示器
+	left := image.Rect(-1280, -200, 0, 824)   // 位于主显示器左上方的显示器
+
+	tests := []struct {
+		name   string
+		move   MouseMove
+		bounds image.Rectangle
+		want   image.Point
+	}{
+		{"unscaled", MouseMove{X: 10, Y: 20}, second, image.Pt(1930, 20)},
+		{"same size", MouseMove{X: 960, Y: 540, Width: 1920, Height: 1080}, second, image.Pt(2880, 540)},
+		{"scaled down viewer", MouseMove{X: 640, Y: 360, Width: 1280, Height: 720}, second, image.Pt(2880, 540)},
+		{"origin", MouseMove{X: 0, Y: 0, Width: 1280, Height: 720}, second, image.Pt(1920, 0)},
+		{"negative desktop coordinates", MouseMove{X: 640, Y: 512, Width: 1280, Height: 1024}, left, image.Pt(-640, 312)},
+		{"right and bottom edge", MouseMove{X: 1280, Y: 720, Width: 1280, Height: 720}, second, image.Pt(3839, 1079)},
+		{"beyond the picture", MouseMove{X: 65535, Y: 65535, Width: 1280, Height: 720}, second, image.Pt(3839, 1079)},
+		{"unscaled beyond the area", MouseMove{X: 5000, Y: 5000}, second, image.Pt(3839, 1079)},
+		{"negative coordinates", MouseMove{X: -10, Y: -10}, left, image.Pt(-1280, -200)},
+		{"zero height uses pixels", MouseMove{X: 100, Y: 100, Width: 1280}, second, image.Pt(2020, 100)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mapMousePosition(&tt.move, tt.bounds); got != tt.want {
+				t.Errorf("mapMousePosition() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyControl(t *testing.T) {
+	savedTarget, savedEncoders := target, encoders
+	defer func() { target, encoders = savedTarget, savedEncoders }()
+	capturer := &testsrcCapturer{width: 1920, height: 1080}
+	target = newTargetSelector(capturer, CaptureTarget{Type: targetDisplay, Display: allDisplays})
+	target.setCaptured(captureArea{bounds: image.Rect(1920, 0, 3840, 1080)})
+	encoders = newEncoderPool(capturer, nil)
+
+	tests := []struct {
+		name    string
+		msg     ControlMessage
+		want    []string
+		wantErr bool
+	}{
+		{"move", ControlMessage{Action: actionMouseMove, Event: &MouseMove{X: 640, Y: 360, Width: 1280, Height: 720}}, []string{"move 2880,540"}, false},
+		{"move out of range", ControlMessage{Action: actionMouseMove, Event: &MouseMove{X: 2000, Y: 900, Width: 1280, Height: 720}}, []string{"move 3839,1079"}, false},
+		{"button down", ControlMessage{Action: actionMouseDown, Event: &MouseButton{Button: "right"}}, []string{"button right down"}, false},
+		{"button up", ControlMessage{Action: actionMouseUp, Event: &MouseButton{Button: "right"}}, []string{"button right up"}, false},
+		{"click", ControlMessage{Action: actionMouseClick, Event: &MouseButton{Button: "left"}}, []string{"button left down", "button left up"}, false},
+		{"double click", ControlMessage{Action: actionDoubleClick, Event: &MouseButton{Button: "left"}},
+			[]string{"button left down", "button left up", "button left down", "button left up"}, false},
+		{"wheel", ControlMessage{Action: actionMouseWheel, Event: &MouseWheel{DeltaX: 0, DeltaY: -3}}, []string{"wheel 0,-3"}, false},
+		{"modifier key down", ControlMessage{Action: actionKeyDown, Event: &KeyEvent{Key: "ShiftLeft"}}, []string{"key ShiftLeft down"}, false},
+		{"key up", ControlMessage{Action: actionKeyUp, Event: &KeyEvent{Key: "KeyA"}}, []string{"key KeyA up"}, false},
+		{"key press with modifiers", ControlMessage{Action: actionKeyPress, Event: &KeyEvent{Key: "KeyC", Modifiers: []string{"ctrl"}}},
+			[]string{"key KeyC ctrl down", "key KeyC ctrl up"}, false},
+		{"text", ControlMessage{Action: actionTypeText, Event: &TextInput{Text: "你好"}}, []string{`text "你好"`}, false},
+		{"request control", ControlMessage{Action: actionRequestControl}, nil, false},
+		{"select display", ControlMessage{Action: actionSelectDisplay, Event: &CaptureTarget{Type: targetDisplay, Display: 0}}, nil, false},
+		{"select missing display", ControlMessage{Action: actionSelectDisplay, Event: &CaptureTarget{Type: targetDisplay, Display: 3}}, nil, true},
+		{"region too small", ControlMessage{Action: actionCaptureRegion, Event: &CaptureTarget{Type: targetRegion, Width: 1, Height: 1}}, nil, true},
+	}
+	injector := &recordingInjector{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			injector.Reset()
+			err := applyControl(injector, &tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("applyControl() error = %v, wantErr %t", err, tt.wantErr)
+			}
+			if got := injector.Events(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("events = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// 切换目标后新的画面到达之前，鼠标坐标仍按正在发送的画面换算
+	if target.target != (CaptureTarget{Type: targetDisplay, Display: 0}) {
+		t.Errorf("target = %s, want display 0", target.target)
+	}
+	injector.Reset()
+	applyControl(injector, &ControlMessage{Action: actionMouseMove, Event: &MouseMove{X: 0, Y: 0}})
+	if got := injector.Events(); !reflect.DeepEqual(got, []string{"move 1920,0"}) {
+		t.Errorf("events after switching target = %q, want [\"move 1920,0\"]", got)
+	}
+}
@@ -0,0 +1,105 @@
+// ivfstream.go
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/rtp"
+	"github.com/pion/webrtc/v3"
+	"github.com/pion/webrtc/v3/pkg/media/ivfreader"
+)
+
+// ivfStream 将 FFmpeg 输出的 IVF 容器（VP8、VP9、AV1）逐帧打包为 RTP 写入视频轨道。
+// IVF 中每一帧即一个完整的时间单元，RTP 时间戳取自帧头 PTS 换算出的采集时间，打包状态跨 FFmpeg 进程保留。
+type ivfStream struct {
+	mutex      sync.Mutex // 保护打包器和时钟，交接期间两个进程的输出依次发送
+	packetizer rtp.Packetizer
+	track      *webrtc.TrackLocalStaticRTP
+	isKeyframe func(frame []byte) bool
+	clock      rtpClock
+
+	lastKeyframeTime atomic.Int64 // 最近一个关键帧的发送时间，Unix 纳秒
+}
+
+// newIVFStream 创建使用指定打包器和关键帧判断方式的 IVF 流
+func newIVFStream(packetizer rtp.Packetizer, track *webrtc.TrackLocalStaticRTP, isKeyframe func([]byte) bool) *ivfStream {
+	return &ivfStream{packetizer: packetizer, track: track, isKeyframe: isKeyframe}
+}
+
+// lastKeyframe 返回最近一个关键帧的发送时间
+func (s *ivfStream) lastKeyframe() time.Time {
+	return time.Unix(0, s.lastKeyframeTime.Load())
+}
+
+// keyframeRequested 这些编码的关键帧自带全部解码参数，无需额外处理
+func (s *ivfStream) keyframeRequested() {}
+
+// send 读取 IVF 帧，打包为 RTP 写入视频轨道，直到码流结束；accept 返回 false 的帧直接丢弃
+func (s *ivfStream) send(r io.Reader, accept func(keyframe bool) bool) error {
+	reader, header, err := ivfreader.NewWith(r)
+	if err != nil {
+		return fmt.Errorf("read IVF header: %w", err)
+	}
+
+	for {
+		frame, frameHeader, err := reader.ParseNextFrame()
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return fmt.Errorf("read IVF frame: %w", err)
+		}
+
+		s.write(frame, ivfCaptureTime(frameHeader.Timestamp, header, time.Now()), accept)
+	}
+}
+
+// write 将一帧打包为 RTP 写入视频轨道
+func (s *ivfStream) write(frame []byte, captureTime time.Time, accept func(keyframe bool) bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	keyframe := s.isKeyframe(frame)
+	if !accept(keyframe) {
+		return
+	}
+	if keyframe {
+		s.lastKeyframeTime.Store(captureTime.UnixNano())
+	}
+	timestamp := s.clock.timestamp(captureTime)
+	for _, packet := range s.packetizer.Packetize(frame, 0) {
+		packet.Timestamp = timestamp
+		if err := s.track.WriteRTP(packet); err != nil {
+			log.Println("发送 RTP 包时出错:", err)
+		}
+	}
+}
+
+// ivfPTSTolerance PTS 换算出的采集时间与读出时间相差超过该值时，认为 PTS 不是墙上时钟
+const ivfPTSTolerance = 5 * time.Second
+
+// ivfCaptureTime 将 IVF 帧头中的 PTS 按文件头的时间基换算为采集时间。
+// 采集输入以墙上时钟作为 PTS（use_wallclock_as_timestamps），输出保留原始 PTS（copyts），
+// PTS 即自 Unix 纪元起的时间；时间基无效或换算结果与读出时间相差过大时使用读出时间
+func ivfCaptureTime(pts uint64, header *ivfreader.IVFFileHeader, readTime time.Time) time.Time {
+	num, den := uint64(header.TimebaseNumerator), uint64(header.TimebaseDenominator)
+	if num == 0 || den == 0 || pts > math.MaxUint64/num {
+		return readTime
+	}
+	ticks := pts * num
+	// 分别计算整秒和余下的纳秒，避免乘以 1e9 时溢出
+	seconds, rest := ticks/den, ticks%den
+	if seconds > math.MaxInt64 {
+		return readTime
+	}
+	captureTime := time.Unix(int64(seconds), int64(rest*uint64(time.Second)/den))
+	if diff := readTime.Sub(captureTime); diff > ivfPTSTolerance || diff < -ivfPTSTolerance {
+		return readTime
+	}
+	return captureTime
+}
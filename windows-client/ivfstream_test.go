@@ -0,0 +1,35 @@
+// ivfstream_test.go
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/pion/webrtc/v3/pkg/media/ivfreader"
+)
+
+func TestIVFCaptureTime(t *testing.T) {
+	readTime := time.Unix(1700000000, 500_000_000)
+	perFrame := &ivfreader.IVFFileHeader{TimebaseNumerator: 1, TimebaseDenominator: 30}
+	perMillisecond := &ivfreader.IVFFileHeader{TimebaseNumerator: 1, TimebaseDenominator: 1000}
+
+	tests := []struct {
+		name   string
+		pts    uint64
+		header *ivfreader.IVFFileHeader
+		want   time.Time
+	}{
+		{"frame time base", 1700000000 * 30, perFrame, time.Unix(1700000000, 0)},
+		{"fraction of a second", 1700000000*30 + 10, perFrame, time.Unix(1700000000, 333_333_333)},
+		{"millisecond time base", 1700000000_250, perMillisecond, time.Unix(1700000000, 250_000_000)},
+		{"pts starting at zero", 42, perFrame, readTime},
+		{"pts far in the future", 1800000000 * 30, perFrame, readTime},
+		{"zero time base", 1700000000, &ivfreader.IVFFileHeader{}, readTime},
+		{"overflowing pts", 1 << 63, &ivfreader.IVFFileHeader{TimebaseNumerator: 1000, TimebaseDenominator: 1}, readTime},
+	}
+	for _, tt := range tests {
+		if got := ivfCaptureTime(tt.pts, tt.header, readTime); !got.Equal(tt.want) {
+			t.Errorf("%s: ivfCaptureTime() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// keycodes.go
+package main
+
+import (
+	"errors"
+	"slices"
+)
+
+// nativeKey 一个物理按键在各平台上的键码
+type nativeKey struct {
+	scancode uint16 // PC/AT 第一套扫描码，0xE0 前缀的扩展键记为 0xE0xx（Windows）
+	evdev    uint16 // Linux evdev 键码，X11 键码为其加 8
+	name     string // robotgo 按键名，平台不支持按键码注入时使用，为空表示 robotgo 无对应按键
+}
+
+// keyCodes W3C KeyboardEvent.code 与物理按键的对应关系。
+// 按键码描述的是键盘上的位置而不是字符，按键码注入时字符由桌面端的键盘布局决定，
+// 因此 Viewer 与桌面端的布局不同时快捷键等仍按物理位置生效。
+var keyCodes = map[string]nativeKey{
+	"KeyA": {0x1E, 30, "a"},
+	"KeyB": {0x30, 48, "b"},
+	"KeyC": {0x2E, 46, "c"},
+	"KeyD": {0x20, 32, "d"},
+	"KeyE": {0x12, 18, "e"},
+	"KeyF": {0x21, 33, "f"},
+	"KeyG": {0x22, 34, "g"},
+	"KeyH": {0x23, 35, "h"},
+	"KeyI": {0x17, 23, "i"},
+	"KeyJ": {0x24, 36, "j"},
+	"KeyK": {0x25, 37, "k"},
+	"KeyL": {0x26, 38, "l"},
+	"KeyM": {0x32, 50, "m"},
+	"KeyN": {0x31, 49, "n"},
+	"KeyO": {0x18, 24, "o"},
+	"KeyP": {0x19, 25, "p"},
+	"KeyQ": {0x10, 16, "q"},
+	"KeyR": {0x13, 19, "r"},
+	"KeyS": {0x1F, 31, "s"},
+	"KeyT": {0x14, 20, "t"},
+	"KeyU": {0x16, 22, "u"},
+	"KeyV": {0x2F, 47, "v"},
+	"KeyW": {0x11, 17, "w"},
+	"KeyX": {0x2D, 45, "x"},
+	"KeyY": {0x15, 21, "y"},
+	"KeyZ": {0x2C, 44, "z"},
+
+	"Digit1": {0x02, 2, "1"},
+	"Digit2": {0x03, 3, "2"},
+	"Digit3": {0x04, 4, "3"},
+	"Digit4": {0x05, 5, "4"},
+	"Digit5": {0x06, 6, "5"},
+	"Digit6": {0x07, 7, "6"},
+	"Digit7": {0x08, 8, "7"},
+	"Digit8": {0x09, 9, "8"},
+	"Digit9": {0x0A, 10, "9"},
+	"Digit0": {0x0B, 11, "0"},
+
+	"Escape":        {0x01, 1, "esc"},
+	"Minus":         {0x0C, 12, "-"},
+	"Equal":         {0x0D, 13, "="},
+	"Backspace":     {0x0E, 14, "backspace"},
+	"Tab":           {0x0F, 15, "tab"},
+	"BracketLeft":   {0x1A, 26, "["},
+	"BracketRight":  {0x1B, 27, "]"},
+	"Enter":         {0x1C, 28, "enter"},
+	"Semicolon":     {0x27, 39, ";"},
+	"Quote":         {0x28, 40, "'"},
+	"Backquote":     {0x29, 41, "`"},
+	"Backslash":     {0x2B, 43, "\\"},
+	"Comma":         {0x33, 51, ","},
+	"Period":        {0x34, 52, "."},
+	"Slash":         {0x35, 53, "/"},
+	"Space":         {0x39, 57, "space"},
+	"CapsLock":      {0x3A, 58, "capslock"},
+	"IntlBackslash": {0x56, 86, ""},
+	"ScrollLock":    {0x46, 70, ""},
+	"PrintScreen":   {0xE037, 99, "printscreen"},
+	"ContextMenu":   {0xE05D, 127, "menu"},
+
+	"F1":  {0x3B, 59, "f1"},
+	"F2":  {0x3C, 60, "f2"},
+	"F3":  {0x3D, 61, "f3"},
+	"F4":  {0x3E, 62, "f4"},
+	"F5":  {0x3F, 63, "f5"},
+	"F6":  {0x40, 64, "f6"},
+	"F7":  {0x41, 65, "f7"},
+	"F8":  {0x42, 66, "f8"},
+	"F9":  {0x43, 67, "f9"},
+	"F10": {0x44, 68, "f10"},
+	"F11": {0x57, 87, "f11"},
+	"F12": {0x58, 88, "f12"},
+	"F13": {0x64, 183, "f13"},
+	"F14": {0x65, 184, "f14"},
+	"F15": {0x66, 185, "f15"},
+	"F16": {0x67, 186, "f16"},
+	"F17": {0x68, 187, "f17"},
+	"F18": {0x69, 188, "f18"},
+	"F19": {0x6A, 189, "f19"},
+	"F20": {0x6B, 190, "f20"},
+	"F21": {0x6C, 191, "f21"},
+	"F22": {0x6D, 192, "f22"},
+	"F23": {0x6E, 193, "f23"},
+	"F24": {0x76, 194, "f24"},
+
+	"Insert":     {0xE052, 110, "insert"},
+	"Delete":     {0xE053, 111, "delete"},
+	"Home":       {0xE047, 102, "home"},
+	"End":        {0xE04F, 107, "end"},
+	"PageUp":     {0xE049, 104, "pageup"},
+	"PageDown":   {0xE051, 109, "pagedown"},
+	"ArrowUp":    {0xE048, 103, "up"},
+	"ArrowDown":  {0xE050, 108, "down"},
+	"ArrowLeft":  {0xE04B, 105, "left"},
+	"ArrowRight": {0xE04D, 106, "right"},
+
+	"NumLock":        {0x45, 69, "num_lock"},
+	"NumpadDivide":   {0xE035, 98, "num/"},
+	"NumpadMultiply": {0x37, 55, "num*"},
+	"NumpadSubtract": {0x4A, 74, "num-"},
+	"NumpadAdd":      {0x4E, 78, "num+"},
+	"NumpadEnter":    {0xE01C, 96, "num_enter"},
+	"NumpadDecimal":  {0x53, 83, "num."},
+	"NumpadEqual":    {0x59, 117, "num_equal"},
+	"Numpad0":        {0x52, 82, "num0"},
+	"Numpad1":        {0x4F, 79, "num1"},
+	"Numpad2":        {0x50, 80, "num2"},
+	"Numpad3":        {0x51, 81, "num3"},
+	"Numpad4":        {0x4B, 75, "num4"},
+	"Numpad5":        {0x4C, 76, "num5"},
+	"Numpad6":        {0x4D, 77, "num6"},
+	"Numpad7":        {0x47, 71, "num7"},
+	"Numpad8":        {0x48, 72, "num8"},
+	"Numpad9":        {0x49, 73, "num9"},
+
+	"ControlLeft":  {0x1D, 29, "ctrl"},
+	"ShiftLeft":    {0x2A, 42, "shift"},
+	"AltLeft":      {0x38, 56, "alt"},
+	"MetaLeft":     {0xE05B, 125, "cmd"},
+	"ControlRight": {0xE01D, 97, "rctrl"},
+	"ShiftRight":   {0x36, 54, "rshift"},
+	"AltRight":     {0xE038, 100, "ralt"},
+	"MetaRight":    {0xE05C, 126, "rcmd"},
+}
+
+// modifierKeys 控制协议中的修饰键对应的物理按键，统一按左侧的键注入
+var modifierKeys = map[string]string{
+	"ctrl":  "ControlLeft",
+	"shift": "ShiftLeft",
+	"alt":   "AltLeft",
+	"cmd":   "MetaLeft",
+}
+
+// errKeyCodesUnsupported 表示当前平台不支持按键码注入，需退回 robotgo 按键名
+var errKeyCodesUnsupported = errors.New("key code injection is not supported on this platform")
+
+// lookupKey 按 W3C 按键码或 robotgo 按键名查找物理按键
+func lookupKey(key string) (nativeKey, bool) {
+	if native, ok := keyCodes[key]; ok {
+		return native, true
+	}
+	for _, native := range keyCodes {
+		if key != "" && native.name == key {
+			return native, true
+		}
+	}
+	return nativeKey{}, false
+}
+
+// keySequence 返回按下或松开按键时依次注入的物理按键：按下时先按下修饰键，松开时最后松开修饰键
+func keySequence(key nativeKey, modifiers []string, down bool) []nativeKey {
+	keys := make([]nativeKey, 0, len(modifiers)+1)
+	for _, modifier := range modifiers {
+		keys = append(keys, keyCodes[modifierKeys[modifier]])
+	}
+	keys = append(keys, key)
+	if !down {
+		slices.Reverse(keys)
+	}
+	return keys
+}
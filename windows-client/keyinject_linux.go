@@ -0,0 +1,49 @@
+// keyinject_linux.go
+package main
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+	"github.com/jezek/xgb/xtest"
+)
+
+// xtestConn 注入按键所用的 X11 连接，首次注入时建立，出错后重新连接
+var xtestConn struct {
+	sync.Mutex
+	conn *xgb.Conn
+	root xproto.Window
+}
+
+// injectKey 通过 XTest 扩展注入 X11 键码（evdev 键码加 8），由 X 服务器按当前键盘布局转换为字符
+func injectKey(key nativeKey, down bool) error {
+	xtestConn.Lock()
+	defer xtestConn.Unlock()
+	if xtestConn.conn == nil {
+		conn, err := xgb.NewConn()
+		if err != nil {
+			return fmt.Errorf("connect to X server: %w", err)
+		}
+		if err := xtest.Init(conn); err != nil {
+			conn.Close()
+			return fmt.Errorf("init XTEST extension: %w", err)
+		}
+		xtestConn.conn = conn
+		xtestConn.root = xproto.Setup(conn).DefaultScreen(conn).Root
+	}
+
+	eventType := byte(xproto.KeyRelease)
+	if down {
+		eventType = xproto.KeyPress
+	}
+	err := xtest.FakeInputChecked(xtestConn.conn, eventType, byte(key.evdev+8),
+		xproto.TimeCurrentTime, xtestConn.root, 0, 0, 0).Check()
+	if err != nil {
+		xtestConn.conn.Close()
+		xtestConn.conn = nil
+		return fmt.Errorf("fake key input: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,9 @@
+//go:build !windows && !linux
+
+// keyinject_other.go
+package main
+
+// injectKey 当前平台不支持按键码注入，由调用方退回 robotgo 按键名
+func injectKey(key nativeKey, down bool) error {
+	return errKeyCodesUnsupported
+}
@@ -0,0 +1,47 @@
+// keyinject_windows.go
+package main
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+var procSendInput = user32.NewProc("SendInput")
+
+// SendInput 的键盘输入标志
+const (
+	inputKeyboard       = 1
+	keyEventExtendedKey = 0x0001
+	keyEventKeyUp       = 0x0002
+	keyEventScancode    = 0x0008
+)
+
+// keyboardInput 与 Win32 INPUT 结构（type 为 INPUT_KEYBOARD）的内存布局一致，
+// 末尾的填充使其与包含 MOUSEINPUT 的联合体等长
+type keyboardInput struct {
+	inputType uint32
+	ki        struct {
+		vk, scan    uint16
+		flags, time uint32
+		extraInfo   uintptr
+		_           [8]byte
+	}
+}
+
+// injectKey 以扫描码注入按键，由系统按当前键盘布局转换为字符
+func injectKey(key nativeKey, down bool) error {
+	input := keyboardInput{inputType: inputKeyboard}
+	input.ki.scan = key.scancode & 0xFF
+	input.ki.flags = keyEventScancode
+	if key.scancode>>8 == 0xE0 {
+		input.ki.flags |= keyEventExtendedKey
+	}
+	if !down {
+		input.ki.flags |= keyEventKeyUp
+	}
+	n, _, err := procSendInput.Call(1, uintptr(unsafe.Pointer(&input)), unsafe.Sizeof(input))
+	if n != 1 {
+		return fmt.Errorf("send input: %w", err)
+	}
+	return nil
+}
@@ -2,29 +2,22 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
-	"github.com/pion/rtp"
-	"github.com/pion/rtp/codecs"
-	"github.com/pion/webrtc/v3/pkg/media/h264reader"
 
-	"github.com/go-vgo/robotgo"
 	"github.com/gorilla/websocket"
-	"github.com/kbinani/screenshot"
+	"github.com/pion/interceptor/pkg/cc"
+	"github.com/pion/rtcp"
 	"github.com/pion/webrtc/v3"
-	ffmpeg "github.com/u2takey/ffmpeg-go"
-	"image/jpeg"
-	"io"
 	"log"
-	"math/rand"
 	"net/http"
-	"net/url"
 	"os"
 	"os/signal"
-	"strconv"
 	"sync"
 	"syscall"
+	"time"
 )
 
 type CandidatePayload struct {
@@ -33,124 +26,273 @@ type CandidatePayload struct {
 
 // Message 定义信令服务器与客户端之间的消息格式
 type Message struct {
-	Type    string          `json:"type"`    // "register", "offer", "answer", "candidate", "control_command"
-	Payload json.RawMessage `json:"payload"` // SDP 信息、ICE 候选或控制指令
-}
-
-// ControlCommand 定义从 Viewer 接收的控制指令
-type ControlCommand struct {
-	Action string   `json:"action"` // "mouse_move", "mouse_click", "key_press"
-	Params []string `json:"params"` // 参数，例如坐标或键值
+	Type     string          `json:"type"`                // "register", "offer", "answer", "candidate", "control_command", "session_rejected"
+	ViewerID string          `json:"viewer_id,omitempty"` // 消息关联的 Viewer，Desktop 发出的消息据此路由
+	ViewOnly bool            `json:"view_only,omitempty"` // Viewer 的令牌只允许观看，由信令服务器在转发时填写
+	Subject  string          `json:"subject,omitempty"`   // Viewer 的令牌持有者，由信令服务器在转发时填写
+	Payload  json.RawMessage `json:"payload"`             // SDP 信息、ICE 候选或控制指令
 }
 
 // Client 代表一个连接的客户端
 type Client struct {
-	conn *websocket.Conn
-	send chan []byte
-	role string // "desktop" 或 "viewer"
+	conn      *websocket.Conn
+	send      chan []byte
+	role      string     // "desktop" 或 "viewer"
+	sendMutex sync.Mutex // 串行化 WebSocket 写操作
 }
 
-var (
-	upgrader       = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+// ViewerPeer 代表与单个 Viewer 之间的 WebRTC 连接
+type ViewerPeer struct {
+	viewerID       string
 	peerConnection *webrtc.PeerConnection
-	videoTrack     *webrtc.TrackLocalStaticSample
 	dataChannel    *webrtc.DataChannel
-	mutex          = &sync.Mutex{}
-	client         = &Client{send: make(chan []byte), role: "desktop"}
+	encoder        *Encoder              // 提供该 Viewer 所协商编码的视频轨道
+	estimator      cc.BandwidthEstimator // 基于 TWCC 反馈的带宽估计，用于自适应码率
+
+	controlMutex   sync.Mutex // 保护以下控制消息序号和控制权限
+	lastSequence   uint32     // 最近执行的二进制控制消息序号
+	hasSequence    bool
+	viewOnly       bool // 令牌只允许观看，不能获得控制
+	controlGranted bool // 可以控制鼠标、键盘和采集目标
+	controlPending bool // 控制请求等待控制台确认
+	clipboardSync  bool // Viewer 开启了剪贴板同步
+
+	clipboardFormat   string // 正在接收的剪贴板内容格式，以下只在 DataChannel 的消息回调中访问
+	clipboardBuffer   []byte // 已接收的剪贴板分块
+	clipboardOversize bool   // 正在接收的剪贴板内容超过大小限制，丢弃到最后一块
+
+	restartMutex    sync.Mutex  // 保护以下 ICE 重启状态
+	restartTimer    *time.Timer // 待执行的 ICE 重启
+	restartAttempts int         // 连接恢复前已发出的 ICE 重启次数
+	closed          bool
+}
+
+// errNotConnected 表示当前没有可用的信令连接
+var errNotConnected = errors.New("not connected to signaling server")
+
+var (
+	upgrader   = websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+	peers      = make(map[string]*ViewerPeer) // 以 viewer ID 为键，访问时需持有 mutex
+	iceServers []webrtc.ICEServer             // 注册成功时由信令服务器下发，访问时需持有 mutex
+	config     *Config                        // 启动时加载，之后只读
+	mutex      = &sync.Mutex{}
+	client     = &Client{send: make(chan []byte), role: "desktop"}
 )
 
 func main() {
+	var err error
+	config, err = loadConfig(os.Args[1:])
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
+		log.Fatalf("Invalid configuration:\n%v", err)
+	}
+
 	// 捕获中断信号以优雅关闭
 	interrupt := make(chan os.Signal, 1)
 	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
 
-	// 连接中继服务器
-	signalURL := url.URL{Scheme: "ws", Host: "192.168.40.100:8080", Path: "/ws"} // 中继服务器地址
-	log.Printf("Connecting to signaling server: %s", signalURL.String())
+	// 创建启用拥塞控制的 WebRTC API
+	webrtcAPI, err = newWebRTCAPI()
+	if err != nil {
+		log.Fatal("Failed to create WebRTC API:", err)
+	}
+
+	// 启动信令连接，断线后自动重连
+	done := make(chan struct{})
+	go runSignaling(done)
+
+	// 创建编码器池，Viewer 协商出视频编码后启动对应的编码器，捕获屏幕并发送到 WebRTC
+	capturer, err := newCapturer(config.Capture)
+	if err != nil {
+		log.Fatal("Failed to create screen capturer:", err)
+	}
+	initial, err := initialTarget(config.Capture)
+	if err != nil {
+		log.Fatal("Invalid capture target:", err)
+	}
+	target = newTargetSelector(capturer, initial)
+	encoders = newEncoderPool(capturer, done)
 
-	conn, _, err := websocket.DefaultDialer.Dial(signalURL.String(), nil)
+	// 创建输入注入后端，执行 Viewer 的鼠标和键盘控制
+	injector, err = newInputInjector(config.Input, capturer)
 	if err != nil {
-		log.Fatal("Failed to connect to signaling server:", err)
+		log.Fatal("Failed to create input injector:", err)
+	}
+
+	// 读取控制台命令，确认连接和控制请求；需要时在本机提供确认网页
+	if config.Approval.Mode == approvalAuto && len(config.Approval.Allowlist) == 0 {
+		log.Println("Warning: approval mode is auto with an empty allowlist, every viewer holding a valid token connects without local confirmation.")
+	}
+	go runConsole()
+	if config.Approval.Listen != "" {
+		if err := startApprovalPage(config.Approval.Listen); err != nil {
+			log.Fatal("Failed to start approval page:", err)
+		}
 	}
-	defer conn.Close()
-	client.conn = conn
 
-	// 注册为 desktop
-	register := Message{
-		Type:    "register",
-		Payload: json.RawMessage(`{"role": "desktop"}`),
+	// 根据 RTCP 反馈的带宽估计动态调整码率、分辨率和帧率
+	if config.Encoder.Adaptive {
+		go runBitrateController(done)
 	}
-	err = conn.WriteJSON(register)
+
+	// 检查桌面端剪贴板的变化并同步给 Viewer
+	go runClipboardWatcher(done)
+
+	// 等待中断信号
+	<-interrupt
+	log.Println("Received interrupt signal, shutting down.")
+
+	// 清理
+	close(done)
+	closeAllPeers()
+	encoders.wait()
+}
+
+// handleMessages 处理来自信令服务器的消息，直到连接断开或注册被拒绝
+func handleMessages(client *Client, conn *websocket.Conn) error {
+	for {
+		var msg Message
+		err := conn.ReadJSON(&msg)
+		if err != nil {
+			return fmt.Errorf("read message: %w", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Duration(config.PongTimeout)))
+		log.Println("Received message type:", msg.Type)
+
+		switch msg.Type {
+		case "register_success":
+			var data struct {
+				ICEServers []webrtc.ICEServer `json:"ice_servers"`
+			}
+			err := json.Unmarshal(msg.Payload, &data)
+			if err != nil {
+				return fmt.Errorf("unmarshal register_success payload: %w", err)
+			}
+			mutex.Lock()
+			iceServers = data.ICEServers
+			mutex.Unlock()
+			log.Printf("Registered successfully as desktop, received %d ICE server(s).", len(data.ICEServers))
+			// 信令断开期间中断的连接通过 ICE 重启恢复
+			restartStalledPeers()
+		case "register_failed":
+			var data struct {
+				Reason string `json:"reason"`
+			}
+			err := json.Unmarshal(msg.Payload, &data)
+			if err != nil {
+				return fmt.Errorf("unmarshal register_failed payload: %w", err)
+			}
+			return &RegisterError{Reason: data.Reason}
+		case "offer":
+			handleOffer(msg, client)
+		case "answer":
+			handleAnswer(msg.ViewerID, msg.Payload)
+		case "candidate":
+			handleCandidate(msg.ViewerID, msg.Payload)
+		case "candidates":
+			// 信令服务器在发送队列积压时将多条候选合并为一个数组
+			var list []json.RawMessage
+			err := json.Unmarshal(msg.Payload, &list)
+			if err != nil {
+				log.Println("Unmarshal candidates payload failed:", err)
+				continue
+			}
+			for _, payload := range list {
+				handleCandidate(msg.ViewerID, payload)
+			}
+		case "viewer_disconnected":
+			var data struct {
+				ViewerID string `json:"viewer_id"`
+			}
+			err := json.Unmarshal(msg.Payload, &data)
+			if err != nil {
+				log.Println("Unmarshal viewer_disconnected payload failed:", err)
+				continue
+			}
+			log.Printf("Viewer %s disconnected.", data.ViewerID)
+			approvals.forget(data.ViewerID)
+			closePeer(data.ViewerID)
+		case "desktop_disconnected":
+			log.Println("Viewer disconnected because desktop disconnected.")
+		default:
+			log.Println("Unknown message type:", msg.Type)
+		}
+	}
+}
+
+// newViewerPeer 为指定 Viewer 创建 PeerConnection，根据其 Offer 选择视频编码，
+// 添加该编码的共享视频轨道和控制 DataChannel。viewOnly 为令牌只允许观看，
+// 否则按配置决定 Viewer 连接后是否可以直接控制。
+func newViewerPeer(viewerID string, viewOnly bool, offer webrtc.SessionDescription, client *Client) (*ViewerPeer, error) {
+	codec, err := negotiateCodec(offer)
 	if err != nil {
-		log.Fatal("Failed to send register message:", err)
-	}
-
-	// 启动一个协程来接收消息
-	go handleMessages(client)
-
-	// 定义 TURN 服务器信息
-	turnServerURL := fmt.Sprintf("turn:%s:%d", "192.168.40.100", 23478)
-	turnUsername := "jimmy"
-	turnPassword := "apple"
-	// 创建 WebRTC PeerConnection
-	peerConnection, err = webrtc.NewPeerConnection(webrtc.Configuration{
-		ICEServers: []webrtc.ICEServer{
-			{
-				URLs:       []string{turnServerURL},
-				Username:   turnUsername,
-				Credential: turnPassword,
-			},
-		},
-		ICETransportPolicy: webrtc.ICETransportPolicyRelay,
-	})
+		return nil, err
+	}
+	encoder, err := encoders.get(codec)
 	if err != nil {
-		log.Fatal("Failed to create PeerConnection:", err)
+		return nil, err
 	}
 
-	// 创建视频轨道
-	// 创建视频轨道，指定使用 H.264 编码器
-	videoTrack, err := webrtc.NewTrackLocalStaticRTP(webrtc.RTPCodecCapability{
-		MimeType: webrtc.MimeTypeH264,
-	}, "video", "desktop")
+	// 使用信令服务器在注册成功时下发的 TURN 临时凭证及配置中的 ICE 服务器创建 WebRTC PeerConnection
+	mutex.Lock()
+	servers := append(append([]webrtc.ICEServer{}, iceServers...), config.ICEServers...)
+	mutex.Unlock()
+	peerConnection, estimator, err := newPeerConnection(webrtc.Configuration{
+		ICEServers:         servers,
+		ICETransportPolicy: config.iceTransportPolicy(),
+	})
 	if err != nil {
-		log.Fatal("Failed to create video track:", err)
+		return nil, fmt.Errorf("create PeerConnection: %w", err)
 	}
 
-	_, err = peerConnection.AddTrack(videoTrack)
+	rtpSender, err := peerConnection.AddTrack(encoder.track)
 	if err != nil {
-		log.Fatal("Failed to add video track:", err)
+		peerConnection.Close()
+		return nil, fmt.Errorf("add video track: %w", err)
 	}
-	log.Println("Added video track.")
+
+	// 持续读取 RTCP，处理关键帧请求；NACK 和拥塞控制拦截器也只有在读取时才会处理反馈
+	go readRTCP(viewerID, encoder, rtpSender)
 
 	// 创建 Data Channel 用于接收控制指令
-	dataChannel, err = peerConnection.CreateDataChannel("control", nil)
+	dataChannel, err := peerConnection.CreateDataChannel("control", nil)
 	if err != nil {
-		log.Fatal("Failed to create DataChannel:", err)
+		peerConnection.Close()
+		return nil, fmt.Errorf("create DataChannel: %w", err)
+	}
+
+	peer := &ViewerPeer{
+		viewerID:       viewerID,
+		peerConnection: peerConnection,
+		dataChannel:    dataChannel,
+		encoder:        encoder,
+		estimator:      estimator,
+		viewOnly:       viewOnly,
+		controlGranted: !viewOnly && !config.Control.ViewOnly,
+		clipboardSync:  config.Clipboard.Mode != clipboardOff,
 	}
+	log.Printf("Viewer %s negotiated %s (control: %t).", viewerID, codec.name, peer.controlGranted)
 
 	// 处理控制指令
-	dataChannel.OnMessage(func(msg webrtc.DataChannelMessage) {
-		handleControlCommand(msg.Data)
+	dataChannel.OnMessage(peer.handleControlMessage)
+
+	// DataChannel 打开后发送显示器列表、控制权限和剪贴板同步策略，Viewer 据此切换采集的显示器或请求控制
+	dataChannel.OnOpen(func() {
+		sendDisplays(peer)
+		sendControlStatus(peer)
+		sendClipboardPolicy(peer)
 	})
 
-	// 处理 ICE 连接状态变化
+	// 处理 ICE 连接状态变化：断开或失败时尝试 ICE 重启，只影响该 Viewer
 	peerConnection.OnICEConnectionStateChange(func(state webrtc.ICEConnectionState) {
-		log.Println("ICE Connection State changed:", state.String())
+		log.Printf("ICE Connection State for viewer %s changed: %s", viewerID, state.String())
 
-		if state == webrtc.ICEConnectionStateFailed || state == webrtc.ICEConnectionStateDisconnected {
-			log.Printf("ICE Connection  %v:", state)
-			log.Println("ICE connection failed or disconnected, exiting.")
-			err := peerConnection.Close()
-			if err != nil {
-				fmt.Printf("%v", err)
-				return
-			}
-
-			os.Exit(0)
-		}
 		if state == webrtc.ICEConnectionStateConnected {
-			log.Println("ICE connection established.")
+			log.Printf("ICE connection for viewer %s established.", viewerID)
 		}
+		peer.onICEStateChange(state)
 	})
 
 	// 处理 ICE Candidate
@@ -164,188 +306,113 @@ func main() {
 			return
 		}
 		msg := Message{
-			Type:    "candidate",
-			Payload: json.RawMessage(candidateJSON),
+			Type:     "candidate",
+			ViewerID: viewerID,
+			Payload:  json.RawMessage(candidateJSON),
 		}
 		sendMessage(client, msg)
-		log.Println("Sent ICE candidate.")
+		log.Printf("Sent ICE candidate to viewer %s.", viewerID)
 	})
 
-	// 启动 FFmpeg 进程，捕获屏幕并输出到管道
-
-	// 创建一个管道，用于捕获 FFmpeg 的输出
-	ffmpegReader, ffmpegWriter := io.Pipe()
-
-	// 启动 FFmpeg 进程并将输出重定向到管道
-	go func() {
-		err := ffmpeg.Input("desktop",
-			ffmpeg.KwArgs{
-				"f":         "gdigrab",
-				"framerate": "30", // 根据需要调整帧率
-			}).
-			Output("pipe:1",
-				ffmpeg.KwArgs{
-					"vcodec":   "libx264",   // 使用 H.264 编码器
-					"preset":   "ultrafast", // 根据需要调整预设
-					"tune":     "zerolatency",
-					"pix_fmt":  "yuv420p",
-					"f":        "h264",  // 输出裸 H.264 流（Annex B 格式）
-					"g":        "15",    // 设置关键帧间隔，调整为适合的值
-					"loglevel": "quiet", // 禁用 FFmpeg 日志输出，可根据需要调整
-				}).
-			WithOutput(ffmpegWriter).
-			Run()
-		if err != nil {
-			log.Println("FFmpeg 进程出错:", err)
-		}
-		ffmpegWriter.Close()
-	}()
-
-	// 读取 FFmpeg 输出并发送到 WebRTC
-	var wg sync.WaitGroup
-	wg.Add(1)
+	return peer, nil
+}
 
-	go func() {
-		defer wg.Done()
-		// 使用 h264reader 读取 NAL 单元
-		h264Reader, err := h264reader.NewReader(ffmpegReader)
+// readRTCP 读取 Viewer 发来的 RTCP，收到 PLI/FIR 时请求编码器输出关键帧，直到发送器关闭
+func readRTCP(viewerID string, encoder *Encoder, rtpSender *webrtc.RTPSender) {
+	for {
+		packets, _, err := rtpSender.ReadRTCP()
 		if err != nil {
-			log.Fatal("Failed to create H264 reader:", err)
+			return
 		}
-
-		// 创建 RTP Packetizer
-		payloadType := uint8(96) // 确保与 SDP 中的 PayloadType 一致
-		ssrc := rand.Uint32()    // 随机生成 SSRC
-
-		packetizer := rtp.NewPacketizer(
-			1200,        // MTU，最大传输单元
-			payloadType, // PayloadType，需与 SDP 中一致
-			ssrc,        // SSRC，随机生成
-			&codecs.H264Payloader{},
-			rtp.NewRandomSequencer(),
-			90000, // 时钟频率，视频通常为 90000
-		)
-
-		var (
-			sps       []byte
-			pps       []byte
-			timestamp uint32 = 0
-		)
-
-		for {
-			// 读取 NAL 单元
-			nal, err := h264Reader.NextNAL()
-			if err != nil {
-				if err == io.EOF {
-					break
-				}
-				log.Println("读取 NAL 单元时出错:", err)
-				continue
-			}
-
-			switch nal.UnitType {
-			case h264reader.NalUnitTypeSPS:
-				sps = append([]byte{}, nal.Data...)
-				continue
-			case h264reader.NalUnitTypePPS:
-				pps = append([]byte{}, nal.Data...)
-				continue
-			case h264reader.NalUnitTypeCodedSliceIdr:
-				// 在发送 IDR 帧之前，先发送 SPS 和 PPS
-				if sps != nil && pps != nil {
-					// 发送 SPS
-					spsPackets := packetizer.Packetize(sps, timestamp)
-					for _, packet := range spsPackets {
-						if err := videoTrack.WriteRTP(packet); err != nil {
-							log.Println("发送 SPS RTP 包时出错:", err)
-						}
-					}
-
-					// 发送 PPS
-					ppsPackets := packetizer.Packetize(pps, timestamp)
-					for _, packet := range ppsPackets {
-						if err := videoTrack.WriteRTP(packet); err != nil {
-							log.Println("发送 PPS RTP 包时出错:", err)
-						}
-					}
-				}
-			}
-
-			// 打包 NAL 单元为 RTP 包
-			packets := packetizer.Packetize(nal.Data, timestamp)
-			for _, packet := range packets {
-				// 发送 RTP 包
-				if err := videoTrack.WriteRTP(packet); err != nil {
-					log.Println("发送 RTP 包时出错:", err)
-				}
+		for _, packet := range packets {
+			switch packet.(type) {
+			case *rtcp.PictureLossIndication, *rtcp.FullIntraRequest:
+				log.Printf("Viewer %s requested a keyframe (%T).", viewerID, packet)
+				encoder.RequestKeyframe()
 			}
-
-			// 更新时间戳，假设帧率为 15 fps
-			timestamp += 90000 / 30
-
 		}
-	}()
+	}
+}
 
-	// 等待中断信号
-	<-interrupt
-	log.Println("Received interrupt signal, shutting down.")
+// getPeer 返回指定 Viewer 的连接，不存在时返回 nil
+func getPeer(viewerID string) *ViewerPeer {
+	mutex.Lock()
+	defer mutex.Unlock()
+	return peers[viewerID]
+}
 
-	// 清理
-	err = peerConnection.Close()
+// closePeer 关闭并移除指定 Viewer 的连接
+func closePeer(viewerID string) {
+	mutex.Lock()
+	peer, ok := peers[viewerID]
+	delete(peers, viewerID)
+	mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	peer.stopICERestart()
+	err := peer.peerConnection.Close()
 	if err != nil {
-		log.Println("Failed to close PeerConnection:", err)
+		log.Printf("Failed to close PeerConnection for viewer %s: %v", viewerID, err)
 	}
-	wg.Wait()
 }
 
-// handleMessages 处理来自信令服务器的消息
-func handleMessages(client *Client) {
-	for {
-		var msg Message
-		err := client.conn.ReadJSON(&msg)
-		if err != nil {
-			log.Println("Read message failed:", err)
-			return
-		}
-		log.Println("Received message type:", msg.Type)
+// closeAllPeers 关闭所有 Viewer 的连接
+func closeAllPeers() {
+	mutex.Lock()
+	viewerIDs := make([]string, 0, len(peers))
+	for viewerID := range peers {
+		viewerIDs = append(viewerIDs, viewerID)
+	}
+	mutex.Unlock()
 
-		switch msg.Type {
-		case "register_success":
-			log.Println("Registered successfully as desktop.")
-		case "register_failed":
-			var data struct {
-				Reason string `json:"reason"`
-			}
-			err := json.Unmarshal(msg.Payload, &data)
-			if err != nil {
-				log.Println("Unmarshal register_failed payload failed:", err)
-				return
-			}
-			log.Fatalf("Register failed: %s", data.Reason)
-		case "offer":
-			handleOffer(msg.Payload, client)
-		case "answer":
-			handleAnswer(msg.Payload)
-		case "candidate":
-			handleCandidate(msg.Payload)
-		case "desktop_disconnected":
-			log.Println("Viewer disconnected because desktop disconnected.")
-		default:
-			log.Println("Unknown message type:", msg.Type)
-		}
+	for _, viewerID := range viewerIDs {
+		closePeer(viewerID)
 	}
 }
 
-// handleOffer 处理来自 Viewer 的 Offer 并发送 Answer
-func handleOffer(payload json.RawMessage, client *Client) {
+// handleOffer 处理来自 Viewer 的 Offer 并发送 Answer；新的 Viewer 需先经本地确认，
+// 等待确认期间不创建 PeerConnection
+func handleOffer(msg Message, client *Client) {
+	viewerID := msg.ViewerID
+	if viewerID == "" {
+		log.Println("Offer without viewer ID, ignoring.")
+		return
+	}
 
 	var offer webrtc.SessionDescription
-	err := json.Unmarshal(payload, &offer)
+	err := json.Unmarshal(msg.Payload, &offer)
 	if err != nil {
 		log.Println("Unmarshal offer failed:", err)
 		return
 	}
 
+	// 每个 Viewer 使用独立的 PeerConnection，重复的 Offer 复用已有连接进行重协商；
+	// DTLS 指纹变化说明 Viewer 已新建了连接，此时丢弃旧连接
+	peer := getPeer(viewerID)
+	if peer != nil && peer.peerConnection.RemoteDescription() != nil &&
+		dtlsFingerprint(peer.peerConnection.RemoteDescription().SDP) != dtlsFingerprint(offer.SDP) {
+		log.Printf("Viewer %s started a new PeerConnection, replacing the old one.", viewerID)
+		closePeer(viewerID)
+		peer = nil
+	}
+	if peer == nil {
+		if !approvals.admit(msg, client) {
+			return
+		}
+		peer, err = newViewerPeer(viewerID, msg.ViewOnly, offer, client)
+		if err != nil {
+			log.Printf("Failed to create peer for viewer %s: %v", viewerID, err)
+			return
+		}
+		mutex.Lock()
+		peers[viewerID] = peer
+		mutex.Unlock()
+		log.Printf("Created PeerConnection for viewer %s.", viewerID)
+	}
+	peerConnection := peer.peerConnection
+
 	// 设置远程描述
 	err = peerConnection.SetRemoteDescription(offer)
 	if err != nil {
@@ -373,16 +440,16 @@ func handleOffer(payload json.RawMessage, client *Client) {
 		log.Println("Marshal answer failed:", err)
 		return
 	}
-	msg := Message{
-		Type:    "answer",
-		Payload: json.RawMessage(answerJSON),
-	}
-	sendMessage(client, msg)
-	log.Println("Sent answer to Viewer.:")
+	sendMessage(client, Message{
+		Type:     "answer",
+		ViewerID: viewerID,
+		Payload:  json.RawMessage(answerJSON),
+	})
+	log.Printf("Sent answer to viewer %s.", viewerID)
 }
 
 // handleAnswer 处理来自信令服务器的 Answer（通常不需要，Answer 由 Viewer 发送）
-func handleAnswer(payload json.RawMessage) {
+func handleAnswer(viewerID string, payload json.RawMessage) {
 	// 在 Desktop 端通常不需要处理 Answer，因为 Desktop 只是发送视频轨道，并不接收媒体流。
 	var answer webrtc.SessionDescription
 	err := json.Unmarshal(payload, &answer)
@@ -391,16 +458,22 @@ func handleAnswer(payload json.RawMessage) {
 		return
 	}
 
+	peer := getPeer(viewerID)
+	if peer == nil {
+		log.Printf("No PeerConnection for viewer %s, ignoring answer.", viewerID)
+		return
+	}
+
 	log.Println("Setting remote description with answer.")
-	err = peerConnection.SetRemoteDescription(answer)
+	err = peer.peerConnection.SetRemoteDescription(answer)
 	if err != nil {
 		log.Println("SetRemoteDescription failed:", err)
 		return
 	}
 }
 
-// handleCandidate 处理来自 Viewer 的 ICE Candidate 并添加到 PeerConnection
-func handleCandidate(payload json.RawMessage) {
+// handleCandidate 处理来自 Viewer 的 ICE Candidate 并添加到对应的 PeerConnection
+func handleCandidate(viewerID string, payload json.RawMessage) {
 	var candidatePayload CandidatePayload
 	fmt.Printf("%v\n", string(payload))
 
@@ -409,98 +482,41 @@ func handleCandidate(payload json.RawMessage) {
 		log.Println("Unmarshal ICE candidate failed:", err)
 		return
 	}
+
+	peer := getPeer(viewerID)
+	if peer == nil {
+		if approvals.holdCandidate(viewerID, payload) {
+			return
+		}
+		log.Printf("No PeerConnection for viewer %s, ignoring ICE candidate.", viewerID)
+		return
+	}
+
 	fmt.Printf("%v\n", candidatePayload)
-	err = peerConnection.AddICECandidate(candidatePayload.Candidate)
+	err = peer.peerConnection.AddICECandidate(candidatePayload.Candidate)
 	if err != nil {
 		log.Println("AddICECandidate failed:", err)
 		return
 	}
-	log.Println("Added ICE candidate to PeerConnection.")
+	log.Printf("Added ICE candidate to PeerConnection for viewer %s.", viewerID)
 }
 
-// sendMessage 发送消息给指定客户端
-func sendMessage(client *Client, message Message) {
+// sendMessage 发送消息给指定客户端，未连接信令服务器时返回错误
+func sendMessage(client *Client, message Message) error {
 	msg, err := json.Marshal(message)
 	if err != nil {
 		log.Println("Marshal message failed:", err)
-		return
+		return err
+	}
+	client.sendMutex.Lock()
+	defer client.sendMutex.Unlock()
+	if client.conn == nil {
+		log.Printf("Not connected to signaling server, dropping %s message.", message.Type)
+		return errNotConnected
 	}
 	err = client.conn.WriteMessage(websocket.TextMessage, msg)
 	if err != nil {
 		log.Println("Write message failed:", err)
 	}
-}
-
-// captureScreen 捕获屏幕并返回 JPEG 编码的数据
-func captureScreen() ([]byte, error) {
-	// 获取第一个显示器的边界
-	n := screenshot.NumActiveDisplays()
-	if n <= 0 {
-		return nil, fmt.Errorf("no active displays found")
-	}
-
-	bounds := screenshot.GetDisplayBounds(0)
-	img, err := screenshot.CaptureRect(bounds)
-	if err != nil {
-		return nil, err
-	}
-
-	// 编码为 JPEG
-	var buf bytes.Buffer
-	err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: 50}) // 质量可调
-	if err != nil {
-		return nil, err
-	}
-
-	return buf.Bytes(), nil
-}
-
-// handleControlCommand 处理来自 DataChannel 的控制指令
-func handleControlCommand(data []byte) {
-	var cmd ControlCommand
-	err := json.Unmarshal(data, &cmd)
-	if err != nil {
-		log.Println("Failed to unmarshal control command:", err)
-		return
-	}
-
-	switch cmd.Action {
-	case "mouse_move":
-		if len(cmd.Params) != 2 {
-			log.Println("Invalid mouse_move parameters")
-			return
-		}
-		x, err1 := strconv.Atoi(cmd.Params[0])
-		y, err2 := strconv.Atoi(cmd.Params[1])
-		if err1 != nil || err2 != nil {
-			log.Println("Invalid mouse_move coordinates")
-			return
-		}
-		robotgo.MoveMouse(x, y)
-	case "mouse_click":
-		if len(cmd.Params) != 1 {
-			log.Println("Invalid mouse_click parameters")
-			return
-		}
-		button := cmd.Params[0]
-		switch button {
-		case "left":
-			robotgo.Click("left", false)
-		case "right":
-			robotgo.Click("right", false)
-		default:
-			log.Println("Unknown mouse button:", button)
-		}
-		log.Printf("Clicked mouse button: %s", button)
-	case "key_press":
-		if len(cmd.Params) != 1 {
-			log.Println("Invalid key_press parameters")
-			return
-		}
-		key := cmd.Params[0]
-		robotgo.KeyTap(key)
-		log.Printf("Pressed key: %s", key)
-	default:
-		log.Println("Unknown control action:", cmd.Action)
-	}
+	return err
 }
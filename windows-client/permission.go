@@ -0,0 +1,90 @@
+// permission.go
+package main
+
+import (
+	"encoding/json"
+	"log"
+
+	"github.com/pion/webrtc/v3"
+)
+
+// 控制请求的处理方式
+const (
+	controlRequestsPrompt = "prompt" // 在桌面端控制台确认
+	controlRequestsGrant  = "grant"  // 自动允许
+	controlRequestsDeny   = "deny"   // 一律拒绝
+)
+
+// ControlStatus 通过 DataChannel 发送给 Viewer 的控制权限
+type ControlStatus struct {
+	Type    string `json:"type"`    // "control"
+	Granted bool   `json:"granted"` // 可以控制鼠标、键盘和采集目标
+	Locked  bool   `json:"locked"`  // 令牌只允许观看，不能请求控制
+	Pending bool   `json:"pending"` // 控制请求等待桌面端确认
+}
+
+// canControl 判断 Viewer 当前是否可以控制桌面
+func (p *ViewerPeer) canControl() bool {
+	p.controlMutex.Lock()
+	defer p.controlMutex.Unlock()
+	return p.controlGranted
+}
+
+// controlStatus 返回 Viewer 当前的控制权限
+func (p *ViewerPeer) controlStatus() ControlStatus {
+	p.controlMutex.Lock()
+	defer p.controlMutex.Unlock()
+	return ControlStatus{Type: "control", Granted: p.controlGranted, Locked: p.viewOnly, Pending: p.controlPending}
+}
+
+// requestControl 处理 Viewer 的控制请求：令牌只允许观看时拒绝，否则按配置自动允许、拒绝或等待控制台确认
+func (p *ViewerPeer) requestControl() {
+	p.controlMutex.Lock()
+	switch {
+	case p.controlGranted:
+	case p.viewOnly:
+		log.Printf("Viewer %s requested control, but its token is view only.", p.viewerID)
+	case config.Control.Requests == controlRequestsGrant:
+		p.controlGranted = true
+		log.Printf("Granted control to viewer %s.", p.viewerID)
+	case config.Control.Requests == controlRequestsPrompt:
+		if !p.controlPending {
+			log.Printf("Viewer %s requests control, type \"grant %s\" or \"deny %s\" to answer.", p.viewerID, p.viewerID, p.viewerID)
+		}
+		p.controlPending = true
+	default:
+		log.Printf("Denied control request from viewer %s.", p.viewerID)
+	}
+	p.controlMutex.Unlock()
+	sendControlStatus(p)
+}
+
+// setControl 允许或收回 Viewer 的控制权限，同时结束待确认的请求；令牌只允许观看的 Viewer 不能获得控制
+func (p *ViewerPeer) setControl(granted bool) bool {
+	p.controlMutex.Lock()
+	if granted && p.viewOnly {
+		p.controlMutex.Unlock()
+		return false
+	}
+	p.controlGranted = granted
+	p.controlPending = false
+	p.controlMutex.Unlock()
+	sendControlStatus(p)
+	return true
+}
+
+// sendControlStatus 通过 DataChannel 向 Viewer 发送其控制权限
+func sendControlStatus(peer *ViewerPeer) {
+	if peer.dataChannel.ReadyState() != webrtc.DataChannelStateOpen {
+		return
+	}
+	data, err := json.Marshal(peer.controlStatus())
+	if err != nil {
+		log.Println("Marshal control status failed:", err)
+		return
+	}
+	err = peer.dataChannel.SendText(string(data))
+	if err != nil {
+		log.Printf("Failed to send control status to viewer %s: %v", peer.viewerID, err)
+	}
+}
@@ -0,0 +1,156 @@
+// signaling.go
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	reconnectInitialDelay = time.Second      // 首次重连等待时间
+	reconnectMaxDelay     = 30 * time.Second // 重连等待时间上限
+)
+
+// RegisterError 表示信令服务器拒绝了注册请求
+type RegisterError struct {
+	Reason string
+}
+
+func (e *RegisterError) Error() string {
+	return "register failed: " + e.Reason
+}
+
+// Fatal 判断拒绝原因是否无法通过重试恢复（如令牌无效），此时应退出而不是重连
+func (e *RegisterError) Fatal() bool {
+	switch e.Reason {
+	case "missing_token", "invalid_token", "token_expired", "role_not_granted",
+		"session_not_granted", "invalid_session", "invalid_role":
+		return true
+	}
+	return false
+}
+
+// runSignaling 维持与信令服务器的连接：断线后按指数退避重连并重新注册。
+// 断线期间保留现有的 PeerConnection，重新注册后通过 ICE 重启恢复；
+// 无法恢复的连接在重启次数用尽后关闭，FFmpeg 采集管道独立运行，不受影响。
+func runSignaling(done <-chan struct{}) {
+	delay := reconnectInitialDelay
+	for {
+		start := time.Now()
+		err := runSignalingSession(done)
+
+		select {
+		case <-done:
+			return
+		default:
+		}
+
+		var registerErr *RegisterError
+		if errors.As(err, &registerErr) && registerErr.Fatal() {
+			log.Fatalf("Register rejected by signaling server: %s", registerErr.Reason)
+		}
+
+		// 连接稳定运行过一段时间后重置退避
+		if time.Since(start) > reconnectMaxDelay {
+			delay = reconnectInitialDelay
+		}
+		wait := delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+		log.Printf("Signaling connection lost (%v), reconnecting in %s.", err, wait)
+		select {
+		case <-done:
+			return
+		case <-time.After(wait):
+		}
+		delay = min(delay*2, reconnectMaxDelay)
+	}
+}
+
+// runSignalingSession 建立一次信令连接并注册为 desktop，阻塞直到连接断开或 done 关闭
+func runSignalingSession(done <-chan struct{}) error {
+	log.Printf("Connecting to signaling server: %s", config.SignalURL)
+	conn, _, err := websocket.DefaultDialer.Dial(config.SignalURL, nil)
+	if err != nil {
+		return fmt.Errorf("connect to signaling server: %w", err)
+	}
+	client.setConn(conn)
+	defer func() {
+		client.setConn(nil)
+		conn.Close()
+	}()
+
+	// 心跳：收到 Ping、Pong 或消息时延长读超时，超时后读循环返回并触发重连
+	pongTimeout := time.Duration(config.PongTimeout)
+	conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongTimeout))
+	})
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(pongTimeout))
+		err := conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+		if errors.Is(err, websocket.ErrCloseSent) {
+			return nil
+		}
+		return err
+	})
+
+	// 定时发送 Ping；收到退出信号时关闭连接，使读循环返回
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		ticker := time.NewTicker(time.Duration(config.PingInterval))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pongTimeout))
+				if err != nil {
+					log.Println("Write ping failed:", err)
+					conn.Close()
+					return
+				}
+			case <-done:
+				conn.Close()
+				return
+			case <-stop:
+				return
+			}
+		}
+	}()
+
+	// 注册为指定会话的 desktop
+	// resume 告知 Viewer 保留现有连接，等待 Desktop 发起 ICE 重启
+	mutex.Lock()
+	resume := len(peers) > 0
+	mutex.Unlock()
+	registerPayload, err := json.Marshal(map[string]interface{}{
+		"role":       "desktop",
+		"session_id": config.SessionID,
+		"token":      config.Token,
+		"resume":     resume,
+	})
+	if err != nil {
+		return fmt.Errorf("marshal register payload: %w", err)
+	}
+	register := Message{
+		Type:    "register",
+		Payload: json.RawMessage(registerPayload),
+	}
+	if err := sendMessage(client, register); err != nil {
+		return fmt.Errorf("send register message: %w", err)
+	}
+
+	return handleMessages(client, conn)
+}
+
+// setConn 替换当前使用的 WebSocket 连接，nil 表示已断开
+func (c *Client) setConn(conn *websocket.Conn) {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+	c.conn = conn
+}
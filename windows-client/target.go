@@ -0,0 +1,242 @@
+// target.go
+package main
+
+import (
+	"fmt"
+	"image"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 采集目标类型
+const (
+	targetDisplay = "display" // 显示器或整个虚拟桌面
+	targetRegion  = "region"  // 虚拟桌面中的矩形区域
+	targetWindow  = "window"  // 按标题查找的应用窗口
+)
+
+// CaptureTarget 描述采集的内容，可由 Viewer 在会话中切换
+type CaptureTarget struct {
+	Type    string `json:"type"`    // "display"、"region" 或 "window"
+	Display int    `json:"display"` // 显示器索引，-1 表示整个虚拟桌面（type 为 display）
+
+	// 区域的虚拟桌面坐标和尺寸（type 为 region）
+	X      int `json:"x,omitempty"`
+	Y      int `json:"y,omitempty"`
+	Width  int `json:"width,omitempty"`
+	Height int `json:"height,omitempty"`
+
+	Title string `json:"title,omitempty"` // 窗口标题，需完全一致（type 为 window）
+}
+
+// captureArea 编码器一次运行所采集的内容
+type captureArea struct {
+	bounds image.Rectangle // 采集区域的虚拟桌面坐标，用于换算鼠标坐标
+	window string          // 采集窗口时为窗口标题，支持直接采集窗口的后端（gdigrab）据此跟随窗口
+}
+
+// targetSelector 管理当前的采集目标，编码器据此确定采集区域，控制指令据此换算鼠标坐标。
+// 显示器列表在启动编码器、切换目标和向 Viewer 发送列表时重新读取，其余时候使用缓存，
+// 避免每次鼠标移动都枚举显示器。
+type targetSelector struct {
+	mutex    sync.Mutex
+	capturer Capturer
+	target   CaptureTarget
+	displays []Display       // 最近一次读取的显示器列表
+	captured image.Rectangle // 正在发送画面的 FFmpeg 进程实际采集的区域，鼠标坐标据此换算
+}
+
+// target 全局采集目标，启动时创建
+var target *targetSelector
+
+// newTargetSelector 创建以指定目标开始采集的选择器
+func newTargetSelector(capturer Capturer, initial CaptureTarget) *targetSelector {
+	return &targetSelector{capturer: capturer, target: initial}
+}
+
+// list 返回可采集的显示器和当前的采集目标
+func (s *targetSelector) list() (DisplayList, error) {
+	list, err := s.refreshDisplays()
+	if err != nil {
+		return DisplayList{}, err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return DisplayList{Type: "displays", Displays: list, Target: s.target}, nil
+}
+
+// refreshDisplays 重新读取显示器列表并更新缓存
+func (s *targetSelector) refreshDisplays() ([]Display, error) {
+	list, err := s.capturer.Displays()
+	if err != nil {
+		return nil, err
+	}
+	s.mutex.Lock()
+	s.displays = list
+	s.mutex.Unlock()
+	return list, nil
+}
+
+// area 返回当前的采集内容：区域被裁剪到虚拟桌面之内；
+// 所选显示器已不存在（例如被拔出）时采集整个虚拟桌面。
+// 使用缓存的显示器列表，尚未读取过时先读取
+func (s *targetSelector) area() (captureArea, error) {
+	s.mutex.Lock()
+	current, list := s.target, s.displays
+	s.mutex.Unlock()
+	if list == nil {
+		var err error
+		if list, err = s.refreshDisplays(); err != nil {
+			return captureArea{}, err
+		}
+	}
+	if len(list) == 0 {
+		return captureArea{}, fmt.Errorf("no active display")
+	}
+
+	desktop := desktopBounds(list)
+	switch current.Type {
+	case targetRegion:
+		bounds := current.bounds().Intersect(desktop)
+		if bounds.Empty() {
+			return captureArea{}, fmt.Errorf("region %v is outside the desktop %v", current.bounds(), desktop)
+		}
+		return captureArea{bounds: bounds}, nil
+	case targetWindow:
+		bounds, err := windowBounds(current.Title)
+		if err != nil {
+			return captureArea{}, err
+		}
+		// gdigrab 直接采集整个窗口；其它后端按区域采集，窗口部分移出屏幕时只采集可见部分
+		if _, ok := s.capturer.(*gdigrabCapturer); !ok {
+			bounds = bounds.Intersect(desktop)
+		}
+		if bounds.Empty() {
+			return captureArea{}, fmt.Errorf("window %q is not visible on the desktop", current.Title)
+		}
+		return captureArea{bounds: bounds, window: current.Title}, nil
+	default:
+		if current.Display >= 0 && current.Display < len(list) {
+			return captureArea{bounds: list[current.Display].bounds()}, nil
+		}
+		return captureArea{bounds: desktop}, nil
+	}
+}
+
+// setCaptured 在 FFmpeg 进程开始发送画面时记录其采集区域。
+// x11grab 等后端在启动时固定采集位置，窗口移动后画面仍是原来的区域，鼠标坐标需按该区域换算
+func (s *targetSelector) setCaptured(area captureArea) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.captured = area.bounds
+}
+
+// inputBounds 返回换算鼠标坐标使用的区域：正在发送的画面的采集区域，尚无画面时为当前的采集区域
+func (s *targetSelector) inputBounds() (image.Rectangle, error) {
+	s.mutex.Lock()
+	captured := s.captured
+	s.mutex.Unlock()
+	if !captured.Empty() {
+		return captured, nil
+	}
+	area, err := s.area()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	return area.bounds, nil
+}
+
+// selectTarget 切换采集目标，所有编码器以新的目标重启，并通知所有 Viewer
+func (s *targetSelector) selectTarget(next CaptureTarget) error {
+	// 切换目标时重新读取显示器列表，显示器增减后区域和窗口也按新的桌面范围裁剪
+	list, err := s.refreshDisplays()
+	if err != nil {
+		return err
+	}
+	switch next.Type {
+	case targetDisplay:
+		if next.Display != allDisplays && (next.Display < 0 || next.Display >= len(list)) {
+			return fmt.Errorf("display %d not found, %d active display(s)", next.Display, len(list))
+		}
+	case targetRegion:
+		if next.Width < 2 || next.Height < 2 {
+			return fmt.Errorf("region %dx%d is too small", next.Width, next.Height)
+		}
+	case targetWindow:
+		// 先确认窗口存在，避免切换后编码器反复启动失败
+		if _, err := windowBounds(next.Title); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown capture target %q", next.Type)
+	}
+
+	s.mutex.Lock()
+	changed := s.target != next
+	s.target = next
+	s.mutex.Unlock()
+	if !changed {
+		return nil
+	}
+
+	log.Printf("Switched capture target to %s.", next)
+	for _, encoder := range encoders.all() {
+		encoder.CaptureChanged()
+	}
+	broadcastDisplays()
+	return nil
+}
+
+// bounds 返回区域目标的矩形
+func (t CaptureTarget) bounds() image.Rectangle {
+	return image.Rect(t.X, t.Y, t.X+t.Width, t.Y+t.Height)
+}
+
+// String 返回便于记录日志的目标描述
+func (t CaptureTarget) String() string {
+	switch t.Type {
+	case targetRegion:
+		return fmt.Sprintf("region %dx%d at (%d,%d)", t.Width, t.Height, t.X, t.Y)
+	case targetWindow:
+		return fmt.Sprintf("window %q", t.Title)
+	default:
+		if t.Display == allDisplays {
+			return "whole desktop"
+		}
+		return fmt.Sprintf("display %d", t.Display)
+	}
+}
+
+// initialTarget 根据采集配置返回初始目标，优先级：窗口 > 区域 > 显示器
+func initialTarget(cfg CaptureConfig) (CaptureTarget, error) {
+	switch {
+	case cfg.Window != "":
+		return CaptureTarget{Type: targetWindow, Title: cfg.Window}, nil
+	case cfg.Region != "":
+		return parseRegion(cfg.Region)
+	default:
+		return CaptureTarget{Type: targetDisplay, Display: cfg.Display}, nil
+	}
+}
+
+// parseRegion 解析 "x,y,width,height" 格式的区域
+func parseRegion(value string) (CaptureTarget, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 4 {
+		return CaptureTarget{}, fmt.Errorf("region %q must be x,y,width,height", value)
+	}
+	var values [4]int
+	for i, part := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return CaptureTarget{}, fmt.Errorf("region %q must be x,y,width,height", value)
+		}
+		values[i] = n
+	}
+	if values[2] < 2 || values[3] < 2 {
+		return CaptureTarget{}, fmt.Errorf("region %q is too small", value)
+	}
+	return CaptureTarget{Type: targetRegion, X: values[0], Y: values[1], Width: values[2], Height: values[3]}, nil
+}
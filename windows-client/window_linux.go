@@ -0,0 +1,74 @@
+// window_linux.go
+package main
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// windowBounds 在 X11 窗口管理器管理的窗口中查找标题完全一致的窗口，返回其在屏幕上的区域。
+// x11grab 只能按固定偏移采集，窗口移动后需要重新选择才能跟随。
+func windowBounds(title string) (image.Rectangle, error) {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return image.Rectangle{}, fmt.Errorf("connect to X server: %w", err)
+	}
+	defer conn.Close()
+
+	root := xproto.Setup(conn).DefaultScreen(conn).Root
+	clientList, err := internAtom(conn, "_NET_CLIENT_LIST")
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	prop, err := xproto.GetProperty(conn, false, root, clientList, xproto.AtomWindow, 0, 1<<16).Reply()
+	if err != nil {
+		return image.Rectangle{}, fmt.Errorf("list windows: %w", err)
+	}
+
+	for i := 0; i+4 <= len(prop.Value); i += 4 {
+		window := xproto.Window(xgb.Get32(prop.Value[i:]))
+		if windowName(conn, window) != title {
+			continue
+		}
+		geometry, err := xproto.GetGeometry(conn, xproto.Drawable(window)).Reply()
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("get geometry of window %q: %w", title, err)
+		}
+		position, err := xproto.TranslateCoordinates(conn, window, root, 0, 0).Reply()
+		if err != nil {
+			return image.Rectangle{}, fmt.Errorf("get position of window %q: %w", title, err)
+		}
+		x, y := int(position.DstX), int(position.DstY)
+		return image.Rect(x, y, x+int(geometry.Width), y+int(geometry.Height)), nil
+	}
+	return image.Rectangle{}, fmt.Errorf("window %q not found", title)
+}
+
+// windowName 返回窗口标题，优先使用 UTF-8 编码的 _NET_WM_NAME
+func windowName(conn *xgb.Conn, window xproto.Window) string {
+	netWMName, err1 := internAtom(conn, "_NET_WM_NAME")
+	utf8String, err2 := internAtom(conn, "UTF8_STRING")
+	if err1 == nil && err2 == nil {
+		prop, err := xproto.GetProperty(conn, false, window, netWMName, utf8String, 0, 1<<10).Reply()
+		if err == nil && len(prop.Value) > 0 {
+			return string(prop.Value)
+		}
+	}
+	prop, err := xproto.GetProperty(conn, false, window, xproto.AtomWmName, xproto.AtomString, 0, 1<<10).Reply()
+	if err != nil {
+		return ""
+	}
+	return string(prop.Value)
+}
+
+// internAtom 返回指定名称的 X11 原子
+func internAtom(conn *xgb.Conn, name string) (xproto.Atom, error) {
+	reply, err := xproto.InternAtom(conn, true, uint16(len(name)), name).Reply()
+	if err != nil {
+		return 0, fmt.Errorf("intern atom %s: %w", name, err)
+	}
+	return reply.Atom, nil
+}
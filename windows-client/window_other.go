@@ -0,0 +1,14 @@
+//go:build !windows && !linux
+
+// window_other.go
+package main
+
+import (
+	"fmt"
+	"image"
+)
+
+// windowBounds 当前平台不支持按标题查找窗口
+func windowBounds(title string) (image.Rectangle, error) {
+	return image.Rectangle{}, fmt.Errorf("window capture is not supported on this platform")
+}
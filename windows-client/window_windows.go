@@ -0,0 +1,40 @@
+// window_windows.go
+package main
+
+import (
+	"fmt"
+	"image"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	user32             = syscall.NewLazyDLL("user32.dll")
+	procFindWindowW    = user32.NewProc("FindWindowW")
+	procGetClientRect  = user32.NewProc("GetClientRect")
+	procClientToScreen = user32.NewProc("ClientToScreen")
+)
+
+// windowBounds 返回标题完全一致的窗口客户区在虚拟桌面中的区域，与 gdigrab 的 title= 采集范围一致
+func windowBounds(title string) (image.Rectangle, error) {
+	name, err := syscall.UTF16PtrFromString(title)
+	if err != nil {
+		return image.Rectangle{}, fmt.Errorf("invalid window title %q: %w", title, err)
+	}
+	hwnd, _, _ := procFindWindowW.Call(0, uintptr(unsafe.Pointer(name)))
+	if hwnd == 0 {
+		return image.Rectangle{}, fmt.Errorf("window %q not found", title)
+	}
+
+	var rect struct{ Left, Top, Right, Bottom int32 }
+	ret, _, err := procGetClientRect.Call(hwnd, uintptr(unsafe.Pointer(&rect)))
+	if ret == 0 {
+		return image.Rectangle{}, fmt.Errorf("get client area of window %q: %w", title, err)
+	}
+	var origin struct{ X, Y int32 }
+	ret, _, err = procClientToScreen.Call(hwnd, uintptr(unsafe.Pointer(&origin)))
+	if ret == 0 {
+		return image.Rectangle{}, fmt.Errorf("get position of window %q: %w", title, err)
+	}
+	return image.Rect(int(origin.X), int(origin.Y), int(origin.X+rect.Right), int(origin.Y+rect.Bottom)), nil
+}